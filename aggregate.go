@@ -0,0 +1,43 @@
+package main
+
+import "time"
+
+// heldAlert is an alert waiting for its aggregation window to end
+type heldAlert struct {
+	store StoreConfig
+	// the first alert of the window. Its old floor is the start of the net move
+	alert Alert
+	// latest value seen during the window
+	floor float64
+	date  time.Time
+}
+
+// flushHeld queues an alert with the net move of every held alert whose window ended.
+// Moves that reverted within the window are dropped
+func (c *cycle) flushHeld() {
+	window := time.Duration(c.config.AlertWindow * float64(time.Second))
+	for _, held := range c.state.due(c.deps.Clock.Now(), window) {
+		first := held.alert
+		if held.store.sameFloor(first.OldFloor, held.floor) {
+			c.state.suppress(suppressedChange)
+			continue
+		}
+		if reason := insignificance(held.store, held.floor, first.OldFloor); reason != "" {
+			c.state.suppress(reason)
+			continue
+		}
+		if held.store.outOfBand(held.floor) {
+			c.state.suppress(suppressedBand)
+			continue
+		}
+		if held.store.PersistOnAlertOnly {
+			c.floors.Set(metricKey(first.Slug, first.Metric), held.floor, held.date)
+		}
+		alert := withPrevious(held.store, newAlert(held.store, first.Recipient, first.Slug, first.Metric, held.floor, first.OldFloor, held.date), first.since)
+		alert.cooldown = first.cooldown
+		if held.store.ReminderMinutes > 0 && first.Metric == "" {
+			c.state.startSlide(first.Slug, alert)
+		}
+		c.queue(held.store.severe(c.annotate(held.store, alert)))
+	}
+}
@@ -0,0 +1,292 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Alert is a floor change that passed all thresholds
+type Alert struct {
+	Slug      string    `json:"slug"`
+	Metric    string    `json:"metric,omitempty"`
+	Floor     float64   `json:"floor"`
+	OldFloor  float64   `json:"old_floor"`
+	Change    float64   `json:"change"`
+	USD       float64   `json:"usd,omitempty"`
+	Recipient string    `json:"recipient"`
+	Date      time.Time `json:"date"`
+	Message   string    `json:"-"`
+	// of the store the alert is from
+	header, footer string
+	// when OldFloor was recorded if the store shows it
+	since time.Time
+	// the alerts of the same slug from several stores shown on this alert's line by merge_slugs
+	merged []Alert
+	// message the alert replies to with telegram.thread_by_slug
+	replyTo int64
+	// starts a cooldown of its key once delivered
+	cooldown bool
+}
+
+func newAlert(store StoreConfig, recipient, slug, metric string, floor, old_floor float64, date time.Time) Alert {
+	return Alert{
+		Slug:      slug,
+		Metric:    metric,
+		Floor:     floor,
+		OldFloor:  old_floor,
+		Change:    (floor - old_floor) / floor * 100,
+		Recipient: recipient,
+		Date:      date,
+		Message:   formatAlert(store, slug, metric, floor, old_floor),
+		header:    store.Header,
+		footer:    store.Footer,
+	}
+}
+
+// withPrevious shows the old floor of alert and since when it was the floor if the store asks for it
+func withPrevious(store StoreConfig, alert Alert, since time.Time) Alert {
+	if !store.ShowPrevious || alert.OldFloor == 0 || since.IsZero() {
+		return alert
+	}
+	alert.since = since
+	alert.Message = formatAlertSince(store, alert.Slug, alert.Metric, alert.Floor, alert.OldFloor, since, alert.Date)
+	return alert
+}
+
+// withMarketContext adds the 24h volume and sales of stats to floor alerts if the store shows them
+func withMarketContext(store StoreConfig, alert Alert, stats Stats) Alert {
+	if !store.ShowMarketContext || alert.Metric != "" {
+		return alert
+	}
+	var parts []string
+	if len(store.VolumeTree) > 0 {
+		volume := "24h vol " + formatFloor(store, stats.Volume)
+		if store.Symbol != "" {
+			volume += " " + store.Symbol
+		}
+		parts = append(parts, volume)
+	}
+	if len(store.SalesTree) > 0 {
+		parts = append(parts, fmt.Sprintf("%v sales", stats.Sales))
+	}
+	if len(parts) == 0 {
+		return alert
+	}
+	alert.Message += " (" + strings.Join(parts, ", ") + ")"
+	return alert
+}
+
+// sendAlerts sends alerts to recipient through every notifier
+// and records the ones delivered by any in the alert log, including those left out of a message by max_alerts_per_message
+func sendAlerts(deps Deps, config Config, state *State, recipient string, alerts []Alert) {
+	if config.Telegram.MergeSlugs {
+		alerts = mergeSlugs(alerts)
+	}
+	size := len(alerts)
+	if config.Telegram.SeparateMessages || config.Telegram.ThreadBySlug {
+		size = 1
+	}
+	for start := 0; start < len(alerts); start += size {
+		batch := alerts[start : start+size]
+		if !sendLimiter.allow() {
+			// over the rate limit. send everything left as one message
+			fmt.Printf("rate limited. combining %d alerts\n", len(alerts)-start)
+			sendLimiter.wait()
+			batch = alerts[start:]
+			size = len(alerts)
+		}
+		shown, hidden := largestMoves(batch, config.Telegram.MaxAlertsPerMessage)
+		message := composeMessage(shown)
+		if hidden > 0 {
+			message += fmt.Sprintf("\n…and %d more", hidden)
+		}
+		sum := sha256.Sum256([]byte(message))
+		if config.Telegram.SkipRepeats && state.repeated(recipient, sum) {
+			fmt.Printf("skipping message to %s identical to the last one\n", recipient)
+			continue
+		}
+		if config.Telegram.ThreadBySlug {
+			shown = threadAlerts(deps, config, state, recipient, shown)
+		}
+		// with only the bus or socket publishing, every alert counts as delivered
+		delivered := len(deps.Notifiers) == 0
+		for _, notifier := range deps.Notifiers {
+			err := notifier.Send(context.Background(), recipient, message, shown)
+			if errors.Is(err, errAnchorGone) {
+				// start a new thread under a new anchor
+				state.setAnchor(recipient, shown[0].Slug, 0)
+				shown = threadAlerts(deps, config, state, recipient, shown)
+				err = notifier.Send(context.Background(), recipient, message, shown)
+			}
+			if err != nil {
+				fmt.Println(err)
+				continue
+			}
+			delivered = true
+		}
+		if !delivered {
+			if config.UndeliveredMax > 0 {
+				err := state.keepUndelivered(recipient, unmerged(batch), config.UndeliveredMax)
+				if err != nil {
+					fmt.Println(err)
+				}
+			}
+			continue
+		}
+		state.sent(recipient, sum)
+		state.countAlerts(unmerged(batch))
+		state.startCooldowns(unmerged(batch))
+		if config.RestartDedupMinutes > 0 {
+			err := state.rememberAlerts(unmerged(batch), config.restartDedup(), deps.Clock.Now())
+			if err != nil {
+				fmt.Println(err)
+			}
+		}
+		if config.AlertLog == "" {
+			continue
+		}
+		err := logAlerts(config.AlertLog, int64(config.AlertLogMaxMB*1024*1024), unmerged(batch))
+		if err != nil {
+			fmt.Println(err)
+		}
+	}
+}
+
+// separator between the stores of a line merged by merge_slugs
+const mergeSeparator = " · "
+
+// mergeSlugs puts floor alerts of the same slug from different stores on the line of the first.
+// Metric alerts are left alone since their lines read differently
+func mergeSlugs(alerts []Alert) []Alert {
+	var merged []Alert
+	index := map[string]int{}
+	for _, alert := range alerts {
+		if alert.Metric != "" {
+			merged = append(merged, alert)
+			continue
+		}
+		i, ok := index[alert.Slug]
+		if !ok {
+			index[alert.Slug] = len(merged)
+			merged = append(merged, alert)
+			continue
+		}
+		if len(merged[i].merged) == 0 {
+			merged[i].merged = []Alert{merged[i]}
+		}
+		merged[i].Message += mergeSeparator + alert.Message
+		merged[i].merged = append(merged[i].merged, alert)
+	}
+	return merged
+}
+
+// unmerged returns alerts with the ones merged by mergeSlugs split up again
+func unmerged(alerts []Alert) []Alert {
+	var all []Alert
+	for _, alert := range alerts {
+		if len(alert.merged) == 0 {
+			all = append(all, alert)
+			continue
+		}
+		all = append(all, alert.merged...)
+	}
+	return all
+}
+
+// largestMoves returns the limit alerts that moved the most and how many were left out.
+// All alerts when limit is 0
+func largestMoves(alerts []Alert, limit int) ([]Alert, int) {
+	if limit <= 0 || len(alerts) <= limit {
+		return alerts, 0
+	}
+	sorted := append([]Alert(nil), alerts...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return math.Abs(sorted[i].Change) > math.Abs(sorted[j].Change)
+	})
+	return sorted[:limit], len(alerts) - limit
+}
+
+// sendTestAlert sends a made up 10% rise of the first watched slug
+// to its recipient through every notifier, the bus and the socket
+func sendTestAlert(deps Deps, config Config, state *State) string {
+	store, slug := StoreConfig{Multiplier: 1, FloorPrecision: 4, PercentPrecision: 2, StoreURL: "%s"}, "example"
+	for _, s := range config.Stores {
+		if s.Enabled && len(s.Slugs) > 0 {
+			store, slug = s, s.Slugs[0]
+			break
+		}
+	}
+	recipient := store.RecipientID
+	if recipient == "" {
+		recipient = config.Telegram.RecipientID
+	}
+	alert := newAlert(store, recipient, slug, "", 1.1, 1, deps.Clock.Now())
+	alert.Message = "test alert. " + alert.Message
+	alerts := []Alert{alert}
+	sendAlerts(deps, config, state, recipient, alerts)
+	deps.Socket.publish(alerts)
+	deps.Bus.publish(alerts)
+	return fmt.Sprintf("test alert for %s sent to %s", slug, recipient)
+}
+
+// composeMessage joins the messages of alerts grouped by store header and footer
+// in the order the groups first appear
+func composeMessage(alerts []Alert) string {
+	var groups [][]Alert
+	index := map[[2]string]int{}
+	for _, alert := range alerts {
+		key := [2]string{alert.header, alert.footer}
+		i, ok := index[key]
+		if !ok {
+			i = len(groups)
+			index[key] = i
+			groups = append(groups, nil)
+		}
+		groups[i] = append(groups[i], alert)
+	}
+	var lines []string
+	for _, group := range groups {
+		if group[0].header != "" {
+			lines = append(lines, group[0].header)
+		}
+		for _, alert := range group {
+			lines = append(lines, alert.Message)
+		}
+		if group[0].footer != "" {
+			lines = append(lines, group[0].footer)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// logAlerts appends alerts as json lines to path.
+// A log of at least limit bytes is first moved to path.1, replacing the previous one
+func logAlerts(path string, limit int64, alerts []Alert) error {
+	if info, err := os.Stat(path); err == nil && limit > 0 && info.Size() >= limit {
+		err = os.Rename(path, path+".1")
+		if err != nil {
+			return err
+		}
+	}
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	enc := json.NewEncoder(file)
+	for _, alert := range alerts {
+		err = enc.Encode(alert)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
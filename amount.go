@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// decimals the floor of a response priced in symbol is scaled down by
+func (s StoreConfig) decimals(symbol string) int {
+	if decimals, ok := s.SymbolDecimals[symbol]; ok {
+		return decimals
+	}
+	return s.AmountDecimals
+}
+
+// convert returns the floor of stats in display_currency at the cycle's usd rates
+// and the store with its currency and symbol switched to it
+func (c *cycle) convert(store StoreConfig, stats Stats) (StoreConfig, float64, error) {
+	from := store.Currency
+	if stats.Symbol != "" {
+		id, ok := store.SymbolIDs[stats.Symbol]
+		if !ok {
+			return store, 0, fmt.Errorf("no symbol_ids entry for %s", stats.Symbol)
+		}
+		from = id
+	}
+	floor := stats.Floor
+	if from != store.DisplayCurrency {
+		fromRate, toRate := c.rates[from], c.rates[store.DisplayCurrency]
+		if fromRate == 0 || toRate == 0 {
+			return store, 0, fmt.Errorf("no usd rate to convert %s to %s", from, store.DisplayCurrency)
+		}
+		floor = floor * fromRate / toRate
+	}
+	store.Currency = store.DisplayCurrency
+	if symbol, ok := coinbaseSymbols[store.DisplayCurrency]; ok {
+		store.Symbol = symbol
+	} else {
+		store.Symbol = strings.ToUpper(store.DisplayCurrency)
+	}
+	return store, floor, nil
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// BusConfig publishes every alert as a json event to a message bus
+type BusConfig struct {
+	// nats or redis
+	Type string `json:"type"`
+	// host:port of the server
+	Address string `json:"address"`
+	// nats subject or redis channel
+	Subject string `json:"subject"`
+	// do not send alerts to telegram, only publish them
+	Only bool `json:"instead_of_telegram"`
+}
+
+// busPublisher speaks just enough of the nats or redis protocol to publish.
+// A nil publisher drops everything
+type busPublisher struct {
+	config BusConfig
+}
+
+func newBusPublisher(config BusConfig) (*busPublisher, error) {
+	if config.Address == "" {
+		return nil, nil
+	}
+	if config.Type != "nats" && config.Type != "redis" {
+		return nil, fmt.Errorf("unknown type %q", config.Type)
+	}
+	if config.Subject == "" {
+		return nil, fmt.Errorf("subject is required")
+	}
+	return &busPublisher{config: config}, nil
+}
+
+// publish sends each alert as an event over a new connection.
+// Connections are not kept since alerts are rare compared to the cost of a dial
+func (b *busPublisher) publish(alerts []Alert) {
+	if b == nil || len(alerts) == 0 {
+		return
+	}
+	conn, err := net.DialTimeout("tcp", b.config.Address, 5*time.Second)
+	if err != nil {
+		fmt.Println("publish:", err)
+		return
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+	reader := bufio.NewReader(conn)
+	if b.config.Type == "nats" {
+		// the server greets with its info before accepting commands
+		if _, err := reader.ReadString('\n'); err != nil {
+			fmt.Println("publish:", err)
+			return
+		}
+		fmt.Fprint(conn, "CONNECT {\"verbose\":false}\r\n")
+	}
+	for _, alert := range alerts {
+		event, err := json.Marshal(alert)
+		if err != nil {
+			fmt.Println("publish:", err)
+			continue
+		}
+		if b.config.Type == "nats" {
+			fmt.Fprintf(conn, "PUB %s %d\r\n%s\r\n", b.config.Subject, len(event), event)
+			continue
+		}
+		fmt.Fprintf(conn, "*3\r\n$7\r\nPUBLISH\r\n$%d\r\n%s\r\n$%d\r\n%s\r\n", len(b.config.Subject), b.config.Subject, len(event), event)
+		reply, err := reader.ReadString('\n')
+		if err != nil || strings.HasPrefix(reply, "-") {
+			fmt.Println("publish:", err, strings.TrimSpace(reply))
+			return
+		}
+	}
+	if b.config.Type == "nats" {
+		// a pong after the pubs means the server processed them
+		fmt.Fprint(conn, "PING\r\n")
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				fmt.Println("publish:", err)
+				return
+			}
+			if strings.HasPrefix(line, "-ERR") {
+				fmt.Println("publish:", strings.TrimSpace(line))
+				return
+			}
+			if strings.HasPrefix(line, "PONG") {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// CallbackQuery is a press of an inline button
+type CallbackQuery struct {
+	ID      string   `json:"id"`
+	Message *Message `json:"message"`
+	Data    string   `json:"data"`
+}
+
+type inlineButton struct {
+	Text         string `json:"text"`
+	CallbackData string `json:"callback_data"`
+}
+
+// alertButtons returns snooze and mute buttons for every slug of alerts
+func alertButtons(alerts []Alert) interface{} {
+	var rows [][]inlineButton
+	seen := map[string]bool{}
+	for _, alert := range alerts {
+		if alert.Slug == "" || seen[alert.Slug] {
+			continue
+		}
+		seen[alert.Slug] = true
+		rows = append(rows, []inlineButton{
+			{Text: "Snooze " + alert.Slug + " 1h", CallbackData: callbackData("snooze", alert.Slug)},
+			{Text: "Mute " + alert.Slug, CallbackData: callbackData("mute", alert.Slug)},
+		})
+	}
+	return map[string]interface{}{"inline_keyboard": rows}
+}
+
+// telegram rejects buttons with longer callback_data in bytes
+const maxCallbackData = 64
+
+// callbackData is action:slug or action#<hash of slug> when that would be too long
+func callbackData(action, slug string) string {
+	data := action + ":" + slug
+	if len(data) <= maxCallbackData {
+		return data
+	}
+	return action + "#" + slugHash(slug)
+}
+
+func slugHash(slug string) string {
+	sum := sha256.Sum256([]byte(slug))
+	return hex.EncodeToString(sum[:8])
+}
+
+// buttonSlug returns the slug of hashed callback data from the slugs of the pressed
+// message or else the watched slugs. Empty if neither has it
+func buttonSlug(ctx commandContext, message *Message, hash string) string {
+	slugs := ctx.deps.Sent.lookup(message.Chat.ID, message.MessageID)
+	for _, store := range ctx.state.watched(ctx.config).Stores {
+		slugs = append(slugs, store.Slugs...)
+	}
+	for _, slug := range slugs {
+		if slugHash(slug) == hash {
+			return slug
+		}
+	}
+	return ""
+}
+
+// handleCallback snoozes or mutes the slug of a pressed alert button of message and returns the confirmation
+func handleCallback(ctx commandContext, message *Message, data string) string {
+	i := strings.IndexAny(data, ":#")
+	if i < 0 {
+		return "unknown button"
+	}
+	action, slug := data[:i], data[i+1:]
+	if data[i] == '#' {
+		slug = buttonSlug(ctx, message, slug)
+		if slug == "" {
+			return "collection no longer watched"
+		}
+	}
+	var until time.Time
+	var reply string
+	switch action {
+	case "snooze":
+		until = ctx.deps.Clock.Now().Add(time.Hour)
+		reply = fmt.Sprintf("%s snoozed until %s", slug, until.Format("15:04"))
+	case "mute":
+		reply = fmt.Sprintf("%s muted. /unmute %s to undo", slug, slug)
+	default:
+		return "unknown button"
+	}
+	err := ctx.state.silence(slug, until)
+	if err != nil {
+		return fmt.Sprintf("could not save: %v", err)
+	}
+	return reply
+}
+
+func unmuteCommand(ctx commandContext, args []string) (string, error) {
+	if len(args) != 1 {
+		return "", fmt.Errorf("expected 1 argument")
+	}
+	err := ctx.state.unsilence(args[0])
+	if err != nil {
+		return "", fmt.Errorf("could not save: %w", err)
+	}
+	return fmt.Sprintf("%s unmuted", args[0]), nil
+}
+
+// answerCallback shows text to whoever pressed the button
+func answerCallback(client *http.Client, telegram TelegramConfig, id, text string) error {
+	payload, err := json.Marshal(map[string]string{"callback_query_id": id, "text": text})
+	if err != nil {
+		return err
+	}
+	res, err := client.Post(telegram.endpoint("answerCallbackQuery"), "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	_, err = telegramError(res)
+	return err
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// responseCache reuses successful GET and POST responses with the same url and body for ttl.
+// Slugs sharing a batch endpoint then cost one request per ttl
+type responseCache struct {
+	next    http.RoundTripper
+	clock   Clock
+	ttl     time.Duration
+	mu      sync.Mutex
+	entries map[[sha256.Size]byte]cachedResponse
+}
+
+type cachedResponse struct {
+	status  int
+	header  http.Header
+	body    []byte
+	fetched time.Time
+}
+
+// newResponseCache wraps next with a cache. Nothing is cached when ttl is 0
+func newResponseCache(next http.RoundTripper, clock Clock, ttl time.Duration) http.RoundTripper {
+	if ttl <= 0 {
+		return next
+	}
+	return &responseCache{next: next, clock: clock, ttl: ttl, entries: map[[sha256.Size]byte]cachedResponse{}}
+}
+
+func (c *responseCache) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != "GET" && req.Method != "POST" {
+		return c.next.RoundTrip(req)
+	}
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+	key := sha256.Sum256([]byte(req.Method + " " + req.URL.String() + "\n" + string(body)))
+	now := c.clock.Now()
+	c.mu.Lock()
+	cached, ok := c.entries[key]
+	c.mu.Unlock()
+	if ok && now.Sub(cached.fetched) < c.ttl {
+		return cached.response(req), nil
+	}
+
+	res, err := c.next.RoundTrip(req)
+	if err != nil || res.StatusCode != http.StatusOK {
+		return res, err
+	}
+	content, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	cached = cachedResponse{status: res.StatusCode, header: res.Header, body: content, fetched: now}
+	c.mu.Lock()
+	for k, entry := range c.entries {
+		if now.Sub(entry.fetched) >= c.ttl {
+			delete(c.entries, k)
+		}
+	}
+	c.entries[key] = cached
+	c.mu.Unlock()
+	return cached.response(req), nil
+}
+
+// response is a copy of the cached response for req
+func (r cachedResponse) response(req *http.Request) *http.Response {
+	return &http.Response{
+		Status:        strconv.Itoa(r.status) + " " + http.StatusText(r.status),
+		StatusCode:    r.status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        r.header.Clone(),
+		Body:          ioutil.NopCloser(bytes.NewReader(r.body)),
+		ContentLength: int64(len(r.body)),
+		Request:       req,
+	}
+}
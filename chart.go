@@ -0,0 +1,186 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io/ioutil"
+	"math"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	chartWidth  = 800
+	chartHeight = 400
+	chartMargin = 20
+)
+
+var (
+	chartGrid = color.RGBA{0xdd, 0xdd, 0xdd, 0xff}
+	chartLine = color.RGBA{0x20, 0x6b, 0xc4, 0xff}
+)
+
+// windowHistory returns the floors of slug from start onwards.
+// The last floor before start is moved to start so the chart begins at the window
+func windowHistory(history []Persisted, slug string, start time.Time) []Persisted {
+	var points []Persisted
+	for _, persisted := range history {
+		if persisted.Slug != slug {
+			continue
+		}
+		if persisted.Date.Before(start) {
+			persisted.Date = start
+			points = []Persisted{persisted}
+			continue
+		}
+		points = append(points, persisted)
+	}
+	return points
+}
+
+// renderChart draws floors as a step line from the first point until end
+func renderChart(points []Persisted, end time.Time) ([]byte, error) {
+	img := image.NewRGBA(image.Rect(0, 0, chartWidth, chartHeight))
+	draw.Draw(img, img.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+
+	low, high := math.Inf(1), math.Inf(-1)
+	for _, point := range points {
+		low = math.Min(low, point.Floor)
+		high = math.Max(high, point.Floor)
+	}
+	// keep flat lines off the edges
+	pad := math.Max((high-low)*0.05, math.Abs(high)*0.01)
+	low, high = low-pad, high+pad
+	start := points[0].Date
+	span := math.Max(end.Sub(start).Seconds(), 1)
+	x := func(date time.Time) int {
+		return chartMargin + int(date.Sub(start).Seconds()/span*(chartWidth-2*chartMargin))
+	}
+	y := func(floor float64) int {
+		return chartHeight - chartMargin - int((floor-low)/(high-low)*(chartHeight-2*chartMargin))
+	}
+
+	for i := 0; i <= 4; i++ {
+		row := chartMargin + i*(chartHeight-2*chartMargin)/4
+		drawLine(img, chartMargin, row, chartWidth-chartMargin, row, chartGrid)
+	}
+	for i, point := range points {
+		next := end
+		if i+1 < len(points) {
+			next = points[i+1].Date
+		}
+		drawLine(img, x(point.Date), y(point.Floor), x(next), y(point.Floor), chartLine)
+		if i+1 < len(points) {
+			drawLine(img, x(next), y(point.Floor), x(next), y(points[i+1].Floor), chartLine)
+		}
+	}
+
+	var buf bytes.Buffer
+	err := png.Encode(&buf, img)
+	return buf.Bytes(), err
+}
+
+// drawLine draws a 2px wide line using Bresenham's algorithm
+func drawLine(img *image.RGBA, x0, y0, x1, y1 int, c color.Color) {
+	dx, dy := abs(x1-x0), -abs(y1-y0)
+	sx, sy := 1, 1
+	if x0 > x1 {
+		sx = -1
+	}
+	if y0 > y1 {
+		sy = -1
+	}
+	err := dx + dy
+	for {
+		img.Set(x0, y0, c)
+		img.Set(x0+1, y0+1, c)
+		if x0 == x1 && y0 == y1 {
+			return
+		}
+		e2 := 2 * err
+		if e2 >= dy {
+			err += dy
+			x0 += sx
+		}
+		if e2 <= dx {
+			err += dx
+			y0 += sy
+		}
+	}
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+func chartCommand(ctx commandContext, args []string) (string, error) {
+	if len(args) < 1 || len(args) > 2 {
+		return "", fmt.Errorf("expected 1 or 2 arguments")
+	}
+	slug := args[0]
+	hours := 24.0
+	if len(args) == 2 {
+		var err error
+		hours, err = strconv.ParseFloat(args[1], 64)
+		if err != nil || hours <= 0 {
+			return "", fmt.Errorf("invalid hours %s", args[1])
+		}
+	}
+	window := strconv.FormatFloat(hours, 'f', -1, 64)
+	end := ctx.deps.Clock.Now()
+	points := windowHistory(ctx.floors.History(), slug, end.Add(-time.Duration(hours*float64(time.Hour))))
+	if len(points) < 2 {
+		return fmt.Sprintf("not enough history for %s in the last %s hours", slug, window), nil
+	}
+	chart, err := renderChart(points, end)
+	if err != nil {
+		return "", err
+	}
+	low, high := points[0].Floor, points[0].Floor
+	for _, point := range points {
+		low = math.Min(low, point.Floor)
+		high = math.Max(high, point.Floor)
+	}
+	caption := fmt.Sprintf("%s %sh low %v high %v last %v", slug, window, low, high, points[len(points)-1].Floor)
+	return "", sendPhoto(ctx.deps.Telegram, ctx.config.Telegram, ctx.chat, caption, chart)
+}
+
+func sendPhoto(client *http.Client, telegram TelegramConfig, chatID, caption string, photo []byte) error {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	form.WriteField("chat_id", chatID)
+	form.WriteField("caption", caption)
+	part, err := form.CreateFormFile("photo", "chart.png")
+	if err != nil {
+		return err
+	}
+	part.Write(photo)
+	err = form.Close()
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", telegram.endpoint("sendPhoto"), &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		content, _ := ioutil.ReadAll(res.Body)
+		return fmt.Errorf("sendPhoto: %s %s", res.Status, content)
+	}
+	return nil
+}
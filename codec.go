@@ -0,0 +1,69 @@
+package main
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// historyCodec encodes the floor history in a history_format
+type historyCodec struct {
+	encode func(history []Persisted) ([]byte, error)
+	decode func(content []byte) ([]Persisted, error)
+}
+
+var historyCodecs = map[string]historyCodec{
+	"json": {
+		encode: func(history []Persisted) ([]byte, error) {
+			return json.Marshal(history)
+		},
+		decode: func(content []byte) ([]Persisted, error) {
+			var history []Persisted
+			err := json.Unmarshal(content, &history)
+			return history, err
+		},
+	},
+	// smaller and faster to read than json for large histories but not human readable
+	"gob": {
+		encode: func(history []Persisted) ([]byte, error) {
+			var buf bytes.Buffer
+			err := gob.NewEncoder(&buf).Encode(history)
+			return buf.Bytes(), err
+		},
+		decode: func(content []byte) ([]Persisted, error) {
+			var history []Persisted
+			err := gob.NewDecoder(bytes.NewReader(content)).Decode(&history)
+			return history, err
+		},
+	},
+}
+
+func encodeHistory(format string, history []Persisted) ([]byte, error) {
+	return historyCodecs[format].encode(history)
+}
+
+// decodeHistory decodes content in format. History written in another format
+// is read too so changing history_format converts it with the next save
+func decodeHistory(format string, content []byte) ([]Persisted, error) {
+	history, err := historyCodecs[format].decode(content)
+	if err == nil {
+		return history, nil
+	}
+	var others []string
+	for other := range historyCodecs {
+		if other != format {
+			others = append(others, other)
+		}
+	}
+	sort.Strings(others)
+	for _, other := range others {
+		converted, otherErr := historyCodecs[other].decode(content)
+		if otherErr == nil {
+			fmt.Printf("history is %s. it is saved as %s from the next save\n", other, format)
+			return converted, nil
+		}
+	}
+	return history, err
+}
@@ -0,0 +1,382 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Update struct {
+	UpdateID        int64            `json:"update_id"`
+	Message         *Message         `json:"message"`
+	CallbackQuery   *CallbackQuery   `json:"callback_query"`
+	MessageReaction *MessageReaction `json:"message_reaction"`
+}
+
+type Message struct {
+	MessageID int64 `json:"message_id"`
+	Chat      struct {
+		ID       int64  `json:"id"`
+		Username string `json:"username"`
+	} `json:"chat"`
+	Text string `json:"text"`
+}
+
+// commandContext is everything a command handler may read or change
+type commandContext struct {
+	deps   Deps
+	config Config
+	state  *State
+	floors *FloorStore
+	// chat the command came from
+	chat string
+	// runs /refresh under the guard of the main loop so it never overlaps a scheduled cycle
+	watcher *watcher
+}
+
+type command struct {
+	name        string
+	usage       string
+	description string
+	run         func(ctx commandContext, args []string) (string, error)
+}
+
+var commands = []command{
+	{"setmax", "<slug> <value>", "only alert while floor is below value", setThreshold("max")},
+	{"setmin", "<slug> <value>", "only alert while floor is above value", setThreshold("min")},
+	{"setchange", "<slug> <pct>", "only alert on moves of at least pct. Prefix with + or - to only alert on rises or drops", setThreshold("change")},
+	{"setref", "<slug> <price> [pct]", "alert once the floor moves pct away from price. pct defaults to reference_change. 0 price to stop", setReference},
+	{"chart", "<slug> [hours]", "chart of the floor over the last hours. Defaults to 24", chartCommand},
+	{"since", "<slug> <YYYY-MM-DD [HH:MM]>", "recorded floor nearest the given time", sinceCommand},
+	{"watch", "<store> <slug>", "fetch slug from the store named store and watch it from the next cycle", watchCommand},
+	{"unwatch", "<slug>", "stop watching slug", unwatchCommand},
+	{"unmute", "<slug>", "undo the snooze and mute buttons of alerts", unmuteCommand},
+	{"diff", "<slug> <slug>", "latest floors of two collections with their ratio and spread", diffCommand},
+	{"portfolio", "", "value of holdings at the latest floors", portfolioCommand},
+	{"refresh", "", "fetch and alert now instead of waiting for the next cycle", refreshCommand},
+	{"testalert", "", "send a made up alert through every notifier", testAlertCommand},
+	{"stats", "", "fetches per store and alerts suppressed by reason since start", statsCommand},
+	{"debug", "<slug>", "status, error and start of the body of the latest fetch of slug", debugCommand},
+}
+
+// listenCommands long polls telegram for commands sent by the configured recipients
+func listenCommands(ctx commandContext) {
+	// long polling outlasts any timeout set on deps.Telegram
+	client := &http.Client{Transport: ctx.deps.Telegram.Transport, Timeout: 60 * time.Second}
+	var offset int64
+	for {
+		updates, err := getUpdates(client, ctx.config.Telegram, offset)
+		if err != nil {
+			fmt.Println(err)
+			time.Sleep(5 * time.Second)
+			continue
+		}
+		for _, update := range updates {
+			offset = update.UpdateID + 1
+			// remote_config may have replaced the stores since the last update
+			ctx.config = ctx.watcher.current()
+			if query := update.CallbackQuery; query != nil && query.Message != nil && isRecipient(ctx.config, query.Message) {
+				err = answerCallback(ctx.deps.Telegram, ctx.config.Telegram, query.ID, handleCallback(ctx, query.Message, query.Data))
+				if err != nil {
+					fmt.Println(err)
+				}
+				continue
+			}
+			if reaction := update.MessageReaction; reaction != nil && isRecipient(ctx.config, &Message{Chat: reaction.Chat}) {
+				reply := handleReaction(ctx, reaction)
+				if reply == "" {
+					continue
+				}
+				err = sendMessage(ctx.deps.Telegram, ctx.config.Telegram, strconv.FormatInt(reaction.Chat.ID, 10), reply)
+				if err != nil {
+					fmt.Println(err)
+				}
+				continue
+			}
+			if update.Message == nil || !isRecipient(ctx.config, update.Message) {
+				continue
+			}
+			ctx.chat = strconv.FormatInt(update.Message.Chat.ID, 10)
+			reply, ok := handleCommand(ctx, update.Message.Text)
+			if !ok || reply == "" {
+				continue
+			}
+			err = sendMessage(ctx.deps.Telegram, ctx.config.Telegram, ctx.chat, reply)
+			if err != nil {
+				fmt.Println(err)
+			}
+		}
+	}
+}
+
+// update types polled for, url encoded. Reactions are only sent when asked for
+const allowedUpdates = "%5B%22message%22%2C%22callback_query%22%2C%22message_reaction%22%5D"
+
+func getUpdates(client *http.Client, telegram TelegramConfig, offset int64) ([]Update, error) {
+	url := fmt.Sprintf("%s?timeout=50&offset=%d&allowed_updates=%s", telegram.endpoint("getUpdates"), offset, allowedUpdates)
+	res, err := client.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("getUpdates: %w", err)
+	}
+	defer res.Body.Close()
+	var response struct {
+		OK          bool     `json:"ok"`
+		Description string   `json:"description"`
+		Result      []Update `json:"result"`
+	}
+	err = json.NewDecoder(res.Body).Decode(&response)
+	if err != nil {
+		return nil, fmt.Errorf("getUpdates: %w", err)
+	}
+	if !response.OK {
+		return nil, fmt.Errorf("getUpdates: %s", response.Description)
+	}
+	return response.Result, nil
+}
+
+// isRecipient ignores messages from chats that do not receive alerts
+func isRecipient(config Config, message *Message) bool {
+	chat := strconv.FormatInt(message.Chat.ID, 10)
+	recipients := []string{config.Telegram.RecipientID}
+	for _, store := range config.Stores {
+		recipients = append(recipients, store.RecipientID)
+	}
+	for _, route := range config.Routes {
+		recipients = append(recipients, route.RecipientID)
+	}
+	for _, recipient := range recipients {
+		if recipient == "" {
+			continue
+		}
+		if recipient == chat || recipient == "@"+message.Chat.Username {
+			return true
+		}
+	}
+	return false
+}
+
+// handleCommand returns false if text is not a known command.
+// Commands that reply on their own return an empty reply
+func handleCommand(ctx commandContext, text string) (string, bool) {
+	fields := strings.Fields(text)
+	if len(fields) == 0 || !strings.HasPrefix(fields[0], "/") {
+		return "", false
+	}
+	// commands see the slugs added through /watch
+	ctx.config = ctx.state.watched(ctx.config)
+	// commands in groups may be sent as /command@botname
+	name := strings.SplitN(strings.TrimPrefix(fields[0], "/"), "@", 2)[0]
+	if name == "help" || name == "start" {
+		return helpText(), true
+	}
+	for _, cmd := range commands {
+		if cmd.name != name {
+			continue
+		}
+		reply, err := cmd.run(ctx, fields[1:])
+		if err != nil {
+			return fmt.Sprintf("%v\nusage: /%s %s", err, cmd.name, cmd.usage), true
+		}
+		return reply, true
+	}
+	return "", false
+}
+
+// helpText lists the registered commands.
+// It is not a registered command itself since it reads the registry
+func helpText() string {
+	lines := []string{"commands:"}
+	for _, cmd := range commands {
+		usage := "/" + cmd.name
+		if cmd.usage != "" {
+			usage += " " + cmd.usage
+		}
+		lines = append(lines, fmt.Sprintf("`%s` %s", usage, cmd.description))
+	}
+	lines = append(lines, "`/help` this list")
+	return strings.Join(lines, "\n")
+}
+
+func isWatched(config Config, slug string) bool {
+	for _, store := range config.Stores {
+		for _, s := range store.Slugs {
+			if s == slug {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func setThreshold(field string) func(ctx commandContext, args []string) (string, error) {
+	return func(ctx commandContext, args []string) (string, error) {
+		if len(args) != 2 {
+			return "", fmt.Errorf("expected 2 arguments")
+		}
+		slug := args[0]
+		if !isWatched(ctx.config, slug) {
+			return "", fmt.Errorf("%s is not watched", slug)
+		}
+		value, err := strconv.ParseFloat(args[1], 64)
+		if err != nil || math.IsNaN(value) || math.IsInf(value, 0) {
+			return "", fmt.Errorf("invalid value %s", args[1])
+		}
+		// only a change may be signed, for its direction
+		if field != "change" && value < 0 {
+			return "", fmt.Errorf("invalid value %s", args[1])
+		}
+		err = ctx.state.setOverride(slug, func(o *Override) {
+			switch field {
+			case "max":
+				o.Max = &value
+			case "min":
+				o.Min = &value
+			case "change":
+				direction := ""
+				if strings.HasPrefix(args[1], "+") {
+					direction = "up"
+				} else if strings.HasPrefix(args[1], "-") {
+					direction = "down"
+				}
+				value = math.Abs(value)
+				o.Change = &value
+				o.Direction = &direction
+			}
+		})
+		if err != nil {
+			return "", fmt.Errorf("could not save: %w", err)
+		}
+		return fmt.Sprintf("%s %s set to %s", slug, field, args[1]), nil
+	}
+}
+
+func setReference(ctx commandContext, args []string) (string, error) {
+	if len(args) < 2 || len(args) > 3 {
+		return "", fmt.Errorf("expected 2 or 3 arguments")
+	}
+	slug := args[0]
+	if !isWatched(ctx.config, slug) {
+		return "", fmt.Errorf("%s is not watched", slug)
+	}
+	price, err := strconv.ParseFloat(args[1], 64)
+	if err != nil || price < 0 {
+		return "", fmt.Errorf("invalid price %s", args[1])
+	}
+	var change *float64
+	if len(args) == 3 {
+		pct, err := strconv.ParseFloat(args[2], 64)
+		if err != nil || pct <= 0 {
+			return "", fmt.Errorf("invalid pct %s", args[2])
+		}
+		change = &pct
+	}
+	err = ctx.state.setOverride(slug, func(o *Override) {
+		o.Reference = &price
+		if change != nil {
+			o.ReferenceChange = change
+		}
+	})
+	if err != nil {
+		return "", fmt.Errorf("could not save: %w", err)
+	}
+	if price == 0 {
+		return fmt.Sprintf("%s reference cleared", slug), nil
+	}
+	return fmt.Sprintf("%s reference set to %s", slug, args[1]), nil
+}
+
+func sinceCommand(ctx commandContext, args []string) (string, error) {
+	if len(args) < 2 || len(args) > 3 {
+		return "", fmt.Errorf("expected 2 or 3 arguments")
+	}
+	date, err := parseTime(strings.Join(args[1:], " "), ctx.deps.Clock.Now().Location())
+	if err != nil {
+		return "", err
+	}
+	persisted, ok := nearestFloor(ctx.floors.History(), args[0], date)
+	if !ok {
+		return fmt.Sprintf("no history for %s", args[0]), nil
+	}
+	return fmt.Sprintf("%s: %v on %s", persisted.Slug, persisted.Floor, persisted.Date.In(date.Location()).Format("2006-01-02 15:04")), nil
+}
+
+// diffCommand compares the latest floors of two slugs.
+// The second slug is formatted like the first so the difference reads in one precision
+func diffCommand(ctx commandContext, args []string) (string, error) {
+	if len(args) != 2 {
+		return "", fmt.Errorf("expected 2 arguments")
+	}
+	var floors [2]float64
+	for i, slug := range args {
+		floors[i] = ctx.floors.Get(slug)
+		if floors[i] == 0 {
+			return fmt.Sprintf("no floor for %s yet", slug), nil
+		}
+	}
+	store, ok := storeOf(ctx.config, args[0])
+	if !ok {
+		// history of a slug no longer watched
+		store = StoreConfig{FloorPrecision: 4, PercentPrecision: 2}
+	}
+	first, second := floors[0], floors[1]
+	lines := []string{
+		fmt.Sprintf("%s: %s", args[0], formatFloor(store, first)),
+		fmt.Sprintf("%s: %s", args[1], formatFloor(store, second)),
+		fmt.Sprintf("ratio %s/%s: %s", args[0], args[1], strconv.FormatFloat(first/second, 'f', 4, 64)),
+		fmt.Sprintf("spread: %s (%s)", formatFloor(store, first-second), formatPercent(store, (first-second)/second*100)),
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+func statsCommand(ctx commandContext, args []string) (string, error) {
+	fetches := ctx.state.fetchCounts()
+	var stores []string
+	for store := range fetches {
+		stores = append(stores, store)
+	}
+	sort.Strings(stores)
+	lines := []string{"fetches:"}
+	if len(stores) == 0 {
+		lines = []string{"no fetches yet"}
+	}
+	for _, store := range stores {
+		stats := fetches[store]
+		average := stats.latency / time.Duration(stats.requests)
+		lines = append(lines, fmt.Sprintf("%s: %d requests avg %v, %d errors", store, stats.requests, average.Round(time.Millisecond), stats.errors))
+	}
+	if alerted := ctx.state.alertCounts(); len(alerted) > 0 {
+		lines = append(lines, "alerts sent:")
+		lines = append(lines, alerted...)
+	}
+	counts := ctx.state.suppressions()
+	if len(counts) == 0 {
+		return strings.Join(append(lines, "no alerts suppressed"), "\n"), nil
+	}
+	var reasons []string
+	for reason := range counts {
+		reasons = append(reasons, reason)
+	}
+	sort.Strings(reasons)
+	lines = append(lines, "suppressed alerts:")
+	for _, reason := range reasons {
+		lines = append(lines, fmt.Sprintf("%s: %d", reason, counts[reason]))
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+func testAlertCommand(ctx commandContext, args []string) (string, error) {
+	return sendTestAlert(ctx.deps, ctx.config, ctx.state), nil
+}
+
+func refreshCommand(ctx commandContext, args []string) (string, error) {
+	if !ctx.watcher.guard.tryLock() {
+		return "a cycle is already running", nil
+	}
+	defer ctx.watcher.guard.unlock()
+	return "refreshed: " + ctx.watcher.cycle(), nil
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+// CompositeConfig is one collection listed on several stores.
+// Its floor aggregates the floors of its sources fetched in the cycle and is alerted on
+// like a slug of the store of the first source, with its own thresholds
+type CompositeConfig struct {
+	Name string `json:"name"`
+	// slugs of the collection on each store
+	Sources []string `json:"sources"`
+	// min, median or mean. Defaults to min
+	Aggregation string  `json:"aggregation"`
+	Max         float64 `json:"max"`
+	Min         float64 `json:"min"`
+	MinChange   float64 `json:"min_change"`
+	Direction   string  `json:"direction"`
+}
+
+var aggregations = map[string]func(floors []float64) float64{
+	"min": func(floors []float64) float64 {
+		low := floors[0]
+		for _, floor := range floors[1:] {
+			if floor < low {
+				low = floor
+			}
+		}
+		return low
+	},
+	"median": func(floors []float64) float64 {
+		sorted := append([]float64(nil), floors...)
+		sort.Float64s(sorted)
+		mid := len(sorted) / 2
+		if len(sorted)%2 == 0 {
+			return (sorted[mid-1] + sorted[mid]) / 2
+		}
+		return sorted[mid]
+	},
+	"mean": func(floors []float64) float64 {
+		sum := 0.0
+		for _, floor := range floors {
+			sum += floor
+		}
+		return sum / float64(len(floors))
+	},
+}
+
+// checkComposites alerts on the composite floors of the sources fetched in the cycle
+func (c *cycle) checkComposites() {
+	for _, composite := range c.config.Composites {
+		store, ok := sourceStore(c.config.Stores, composite.Sources[0])
+		if !ok {
+			continue
+		}
+		var floors []float64
+		for _, source := range composite.Sources {
+			if floor, ok := c.fetched[source]; ok {
+				floors = append(floors, floor)
+			}
+		}
+		if len(floors) == 0 {
+			fmt.Printf("%s: no source fetched\n", composite.Name)
+			continue
+		}
+		floor := aggregations[composite.Aggregation](floors)
+		c.fetched[composite.Name] = floor
+		store = MetricConfig{Max: composite.Max, Min: composite.Min, MinChange: composite.MinChange, Direction: composite.Direction}.apply(store)
+		c.checkChange(c.state.apply(store, composite.Name), composite.Name, "", floor, Stats{})
+	}
+}
+
+// sourceStore returns the enabled store watching slug
+func sourceStore(stores []StoreConfig, slug string) (StoreConfig, bool) {
+	for _, store := range stores {
+		if !store.Enabled {
+			continue
+		}
+		for _, s := range store.Slugs {
+			if s == slug {
+				return store, true
+			}
+		}
+	}
+	return StoreConfig{}, false
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// configSnapshot is the effective thresholds of every watched slug by <store>/<slug>
+// after /watch, /unwatch and threshold commands
+type configSnapshot map[string]map[string]string
+
+func snapshotConfig(config Config, state *State) configSnapshot {
+	snapshot := configSnapshot{}
+	for _, base := range config.Stores {
+		if !base.Enabled {
+			continue
+		}
+		for _, slug := range base.Slugs {
+			store := state.apply(base, slug)
+			format := func(value float64) string {
+				return strconv.FormatFloat(value, 'f', -1, 64)
+			}
+			snapshot[base.label()+"/"+slug] = map[string]string{
+				"max":              format(store.Max),
+				"min":              format(store.Min),
+				"min_change":       format(store.MinChange),
+				"direction":        store.Direction,
+				"reference":        format(store.References[slug]),
+				"reference_change": format(store.ReferenceChange),
+			}
+		}
+	}
+	return snapshot
+}
+
+// configDiff is what changed between two snapshots
+type configDiff struct {
+	Added   []string `json:"added,omitempty"`
+	Removed []string `json:"removed,omitempty"`
+	// e.g. opensea/gemmy max: 10 → 8
+	Changed []string `json:"changed,omitempty"`
+}
+
+func (d configDiff) empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+func diffConfig(old, new configSnapshot) configDiff {
+	var diff configDiff
+	for key, fields := range new {
+		previous, ok := old[key]
+		if !ok {
+			diff.Added = append(diff.Added, key)
+			continue
+		}
+		for field, value := range fields {
+			if previous[field] != value {
+				diff.Changed = append(diff.Changed, fmt.Sprintf("%s %s: %s → %s", key, field, previous[field], value))
+			}
+		}
+	}
+	for key := range old {
+		if _, ok := new[key]; !ok {
+			diff.Removed = append(diff.Removed, key)
+		}
+	}
+	sort.Strings(diff.Added)
+	sort.Strings(diff.Removed)
+	sort.Strings(diff.Changed)
+	return diff
+}
+
+// logConfigChanges prints what changed in the effective config since the last cycle
+// and tells the operator if notify_config_changes is set. The first cycle only takes the snapshot
+func (w *watcher) logConfigChanges(config Config) {
+	snapshot := snapshotConfig(config, w.state)
+	previous := w.snapshot
+	w.snapshot = snapshot
+	if previous == nil {
+		return
+	}
+	diff := diffConfig(previous, snapshot)
+	if diff.empty() {
+		return
+	}
+	content, err := json.Marshal(diff)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Printf("config changed: %s\n", content)
+	if !config.NotifyConfigChanges {
+		return
+	}
+	var lines []string
+	for _, key := range diff.Added {
+		lines = append(lines, "+ "+key)
+	}
+	for _, key := range diff.Removed {
+		lines = append(lines, "- "+key)
+	}
+	lines = append(lines, diff.Changed...)
+	notifyOperator(w.deps, config, "config changed:\n"+strings.Join(lines, "\n"))
+}
@@ -0,0 +1,60 @@
+package main
+
+import "fmt"
+
+// CrossConfig alerts when the latest floor of slug crosses the latest floor of other.
+// Both are compared as recorded so they should be in the same currency
+type CrossConfig struct {
+	Slug  string `json:"slug"`
+	Other string `json:"other"`
+	// below, above or both. Defaults to below
+	Direction string `json:"direction"`
+}
+
+// recordBelow records whether one floor is below another under key and returns whether
+// that changed since it was last recorded. The first relation is only recorded
+func (s *State) recordBelow(key string, below bool) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	previous, ok := s.Below[key]
+	if ok && previous == below {
+		return false
+	}
+	s.Below[key] = below
+	s.dirty = true
+	return ok
+}
+
+// checkCrosses alerts on the crosses whose slug moved below or above its other slug since the last cycle.
+// Equal floors keep the last relation so touching the other floor is not a cross
+func (c *cycle) checkCrosses() {
+	for _, cross := range c.config.Crosses {
+		floor, other := c.floors.Get(cross.Slug), c.floors.Get(cross.Other)
+		if floor == 0 || other == 0 || sameFloor(floor, other) {
+			continue
+		}
+		below := floor < other
+		if !c.state.recordBelow(cross.Slug+"<"+cross.Other, below) {
+			continue
+		}
+		if (below && cross.Direction == "above") || (!below && cross.Direction == "below") {
+			continue
+		}
+		store, ok := sourceStore(c.config.Stores, cross.Slug)
+		if !ok {
+			continue
+		}
+		otherStore, ok := sourceStore(c.config.Stores, cross.Other)
+		if !ok {
+			otherStore = store
+		}
+		moved := "rose above"
+		if below {
+			moved = "fell below"
+		}
+		alert := newAlert(store, c.recipient(store), cross.Slug, "cross", floor, other, c.deps.Clock.Now())
+		alert.Message = fmt.Sprintf("%s %s %s: %s vs %s (%s)", slugLink(store, cross.Slug), moved, slugLink(otherStore, cross.Other),
+			formatFloor(store, floor), formatFloor(otherStore, other), formatPercent(store, alert.Change))
+		c.queue(alert)
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// lastResponse is the outcome of the latest fetch of a slug
+type lastResponse struct {
+	date   time.Time
+	status string
+	// the start of the body, at most debugBodyLimit bytes
+	body []byte
+	err  error
+}
+
+// recordResponse keeps the latest fetch of slug for /debug
+func (s *State) recordResponse(slug string, stats Stats, err error, date time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.responses[slug] = lastResponse{date: date, status: stats.status, body: stats.body, err: err}
+}
+
+func (s *State) lastResponse(slug string) (lastResponse, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	response, ok := s.responses[slug]
+	return response, ok
+}
+
+func debugCommand(ctx commandContext, args []string) (string, error) {
+	if len(args) != 1 {
+		return "", fmt.Errorf("expected 1 argument")
+	}
+	response, ok := ctx.state.lastResponse(args[0])
+	if !ok {
+		return fmt.Sprintf("%s has not been fetched since start", args[0]), nil
+	}
+	message := fmt.Sprintf("%s fetched %s", args[0], response.date.In(ctx.deps.Clock.Now().Location()).Format("2006-01-02 15:04:05"))
+	if response.status != "" {
+		message += " " + response.status
+	}
+	if response.err != nil {
+		message += fmt.Sprintf("\nerror: %v", response.err)
+	}
+	if len(response.body) > 0 {
+		message += fmt.Sprintf("\n```\n%s\n```", response.body)
+	}
+	return message, nil
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// decodeResponse decodes a stats response of format into the shape json decodes to
+// so json_map and list_match_field apply to every format.
+// Numeric csv and xml values become float64
+func decodeResponse(format string, body []byte) (interface{}, error) {
+	switch format {
+	case "", "json":
+		var raw interface{}
+		err := json.Unmarshal(body, &raw)
+		return raw, err
+	case "csv":
+		return decodeCSV(body)
+	case "xml":
+		return decodeXML(body)
+	}
+	return nil, fmt.Errorf("unknown response_format %q", format)
+}
+
+// decodeCSV returns an object per row keyed by the header row.
+// A single row is returned as that object
+func decodeCSV(body []byte) (interface{}, error) {
+	records, err := csv.NewReader(bytes.NewReader(body)).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) < 2 {
+		return nil, fmt.Errorf("csv has no rows")
+	}
+	var rows []interface{}
+	for _, record := range records[1:] {
+		row := map[string]interface{}{}
+		for i, column := range records[0] {
+			if i < len(record) {
+				row[strings.TrimSpace(column)] = scalarValue(record[i])
+			}
+		}
+		rows = append(rows, row)
+	}
+	if len(rows) == 1 {
+		return rows[0], nil
+	}
+	return rows, nil
+}
+
+// decodeXML returns the document as an object keyed by the root element.
+// Elements are objects of their attributes and children by name, the first of repeated children wins.
+// Elements with only text are their text
+func decodeXML(body []byte) (interface{}, error) {
+	dec := xml.NewDecoder(bytes.NewReader(body))
+	root := map[string]interface{}{}
+	type element struct {
+		name     string
+		children map[string]interface{}
+		text     strings.Builder
+	}
+	stack := []*element{{children: root}}
+	for {
+		token, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			e := &element{name: t.Name.Local, children: map[string]interface{}{}}
+			for _, attr := range t.Attr {
+				e.children[attr.Name.Local] = scalarValue(attr.Value)
+			}
+			stack = append(stack, e)
+		case xml.CharData:
+			stack[len(stack)-1].text.Write(t)
+		case xml.EndElement:
+			e := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			var value interface{} = e.children
+			if len(e.children) == 0 {
+				value = scalarValue(e.text.String())
+			}
+			parent := stack[len(stack)-1].children
+			if _, ok := parent[e.name]; !ok {
+				parent[e.name] = value
+			}
+		}
+	}
+	return root, nil
+}
+
+// scalarValue is s as a number if it is one
+func scalarValue(s string) interface{} {
+	s = strings.TrimSpace(s)
+	if value, err := strconv.ParseFloat(s, 64); err == nil {
+		return value
+	}
+	return s
+}
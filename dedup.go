@@ -0,0 +1,41 @@
+package main
+
+import "time"
+
+// recentAlert is the floor of an alert delivered shortly before a restart
+type recentAlert struct {
+	Floor float64   `json:"floor"`
+	Date  time.Time `json:"date"`
+}
+
+func (c Config) restartDedup() time.Duration {
+	return time.Duration(c.RestartDedupMinutes * float64(time.Minute))
+}
+
+// rememberAlerts records delivered alerts by metric key and forgets the ones older than window at now.
+// Saved right away since the cycle's state flush ran before sending
+func (s *State) rememberAlerts(alerts []Alert, window time.Duration, now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, alert := range alerts {
+		s.Recent[metricKey(alert.Slug, alert.Metric)] = recentAlert{alert.Floor, alert.Date}
+	}
+	for key, recent := range s.Recent {
+		if now.Sub(recent.Date) > window {
+			delete(s.Recent, key)
+		}
+	}
+	return s.save()
+}
+
+// repeatsRecent reports whether alert comes within window of startup and repeats
+// the floor of an alert of the same key delivered within window before it
+func (s *State) repeatsRecent(alert Alert, window time.Duration) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if alert.Date.Sub(s.started) > window {
+		return false
+	}
+	recent, ok := s.Recent[metricKey(alert.Slug, alert.Metric)]
+	return ok && alert.Date.Sub(recent.Date) <= window && sameFloor(recent.Floor, alert.Floor)
+}
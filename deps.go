@@ -0,0 +1,139 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Clock is the source of the current time
+type Clock interface {
+	Now() time.Time
+}
+
+// systemClock is the real time in the configured timezone
+type systemClock struct {
+	location *time.Location
+}
+
+func (c systemClock) Now() time.Time {
+	return time.Now().In(c.location)
+}
+
+// Deps are how the bot reaches the outside world.
+// Tests can replace the clock and give the clients a fake http.RoundTripper
+type Deps struct {
+	Clock Clock
+	// reaches s3, rate sources, remote config and heartbeats
+	Client *http.Client
+	// fetches floors from stores. Shares Client's transport and rate limits
+	// but reuses responses for http.cache_seconds
+	Stats *http.Client
+	// sends to and polls telegram
+	Telegram *http.Client
+	// nil unless config.Socket is set
+	Socket *socketBroadcaster
+	// nil unless config.Bus is set
+	Bus *busPublisher
+	// usd prices of store currencies
+	Rates *rateCache
+	// the floor history. history_json_path unless history_s3 is set
+	History blob
+	// every alert message is sent through each
+	Notifiers []Notifier
+	// slugs of recent telegram alerts by message for reactions
+	Sent *sentMessages
+}
+
+// newDeps uses config.Timezone for the clock, config.HTTP for connection reuse
+// and routes requests through config.Proxy if set
+func newDeps(config Config) (Deps, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConns = config.HTTP.MaxIdleConns
+	transport.MaxIdleConnsPerHost = config.HTTP.MaxIdleConnsPerHost
+	transport.IdleConnTimeout = time.Duration(config.HTTP.IdleConnTimeout * float64(time.Second))
+	deps := Deps{
+		Client:   &http.Client{Transport: newHostLimiter(transport, config.RateLimits)},
+		Telegram: &http.Client{Transport: newHeaderTransport(nil, config.Telegram.Headers)},
+		Sent:     newSentMessages(),
+		Rates:    newRateCache(rateSources[config.Rates.Source], time.Duration(config.Rates.CacheMinutes*float64(time.Minute))),
+	}
+	location, err := time.LoadLocation(config.Timezone)
+	if err != nil {
+		return deps, fmt.Errorf("timezone: %w", err)
+	}
+	deps.Clock = systemClock{location}
+	deps.Notifiers = []Notifier{telegramNotifier{client: deps.Telegram, config: config.Telegram, sent: deps.Sent}}
+	deps.Bus, err = newBusPublisher(config.Bus)
+	if err != nil {
+		return deps, fmt.Errorf("publish: %w", err)
+	}
+	if deps.Bus != nil && config.Bus.Only {
+		deps.Notifiers = nil
+	}
+	deps.Stats = &http.Client{Transport: newResponseCache(deps.Client.Transport, deps.Clock, time.Duration(config.HTTP.CacheSeconds*float64(time.Second)))}
+	deps.History = fileBlob(config.Output)
+	if config.S3.Bucket != "" {
+		s3 := s3Blob{client: deps.Client, clock: deps.Clock, config: config.S3}
+		deps.History = s3
+		if config.S3.MirrorFile && config.S3.ReadFrom == "file" {
+			deps.History = mirrorBlob{primary: fileBlob(config.Output), mirror: s3}
+		} else if config.S3.MirrorFile {
+			deps.History = mirrorBlob{primary: s3, mirror: fileBlob(config.Output)}
+		}
+	}
+	deps.History = newSplitBlob(deps.History, config.HistoryFormat, config.Output, config.Stores)
+	if config.ReadRetries > 0 {
+		deps.History = retryBlob{blob: deps.History, retries: config.ReadRetries, delay: readRetryDelay}
+	}
+	if config.Socket != "" {
+		deps.Socket, err = listenSocket(config.Socket)
+		if err != nil {
+			return deps, fmt.Errorf("socket_path: %w", err)
+		}
+	}
+	if config.Proxy == "" {
+		return deps, nil
+	}
+	proxy, err := url.Parse(config.Proxy)
+	if err != nil {
+		return deps, fmt.Errorf("proxy_url: %w", err)
+	}
+	switch proxy.Scheme {
+	case "http", "https", "socks5":
+	default:
+		return deps, fmt.Errorf("proxy_url: unsupported scheme %q", proxy.Scheme)
+	}
+	transport.Proxy = http.ProxyURL(proxy)
+	if !config.Telegram.BypassProxy {
+		deps.Telegram.Transport = newHeaderTransport(transport, config.Telegram.Headers)
+	}
+	return deps, nil
+}
+
+// headerTransport adds headers to every request e.g. for a gateway in front of the bot api
+type headerTransport struct {
+	next    http.RoundTripper
+	headers map[string]string
+}
+
+// newHeaderTransport wraps next, or the default transport if nil, unless there are no headers
+func newHeaderTransport(next http.RoundTripper, headers map[string]string) http.RoundTripper {
+	if len(headers) == 0 {
+		return next
+	}
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return headerTransport{next: next, headers: headers}
+}
+
+func (t headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// a RoundTripper must not modify the caller's request
+	req = req.Clone(req.Context())
+	for name, value := range t.headers {
+		req.Header.Set(name, value)
+	}
+	return t.next.RoundTrip(req)
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func TestStatsCache(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.Write([]byte(`{"floor":1}`))
+	}))
+	defer server.Close()
+	deps, err := newDeps(Config{Timezone: "UTC", HTTP: HTTPConfig{CacheSeconds: 60}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	get := func(client *http.Client) {
+		res, err := client.Get(server.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+	}
+
+	get(deps.Stats)
+	get(deps.Stats)
+	if n := atomic.LoadInt32(&requests); n != 1 {
+		t.Errorf("stats client made %d requests, want 1", n)
+	}
+	// s3, rates, remote config and heartbeats must see fresh responses
+	get(deps.Client)
+	get(deps.Client)
+	if n := atomic.LoadInt32(&requests); n != 3 {
+		t.Errorf("uncached client made %d requests, want 2", n-1)
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// EMA is the exponential moving average of a slug's floor
+type EMA struct {
+	Value float64 `json:"value"`
+	// floor was above the average on the last update
+	Above bool `json:"above"`
+	// floor was beyond ema_deviation on the last update
+	Deviated bool `json:"deviated"`
+	// exponential moving average of how far the floor was from the average
+	Spread float64 `json:"spread"`
+	// move was beyond ema_anomaly on the last update
+	Anomalous bool `json:"anomalous"`
+}
+
+// updateEMA folds floor into the slug's average.
+// Returns an alert message on a crossover, when floor starts deviating from the average
+// or when its distance from the average is unusual for the slug
+func (s *State) updateEMA(store StoreConfig, slug string, floor float64) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.dirty = true
+	ema, ok := s.EMA[slug]
+	if !ok {
+		s.EMA[slug] = &EMA{Value: floor}
+		return ""
+	}
+	// the move is judged against the spread learned before it
+	move := math.Abs(floor - ema.Value)
+	anomalous := store.EMAAnomaly > 0 && ema.Spread > 0 && move > store.EMAAnomaly*ema.Spread
+	deviations := 0.0
+	if ema.Spread > 0 {
+		deviations = move / ema.Spread
+	}
+	ema.Spread = store.EMAAlpha*move + (1-store.EMAAlpha)*ema.Spread
+	ema.Value = store.EMAAlpha*floor + (1-store.EMAAlpha)*ema.Value
+	above := floor > ema.Value
+	deviation := (floor - ema.Value) / ema.Value * 100
+	deviated := store.EMADeviation > 0 && math.Abs(deviation) > store.EMADeviation
+	var msg string
+	if deviated && !ema.Deviated {
+		msg = fmt.Sprintf("%s: %s is %s from EMA %s", slugLink(store, slug), formatFloor(store, floor), formatPercent(store, deviation), formatFloor(store, ema.Value))
+	} else if anomalous && !ema.Anomalous {
+		msg = fmt.Sprintf("%s: %s is %s from EMA %s, %s times its usual move", slugLink(store, slug), formatFloor(store, floor), formatPercent(store, deviation), formatFloor(store, ema.Value), strconv.FormatFloat(math.Round(deviations*10)/10, 'f', -1, 64))
+	} else if store.EMACrossover && above != ema.Above {
+		direction := "below"
+		if above {
+			direction = "above"
+		}
+		msg = fmt.Sprintf("%s: %s crossed %s EMA %s", slugLink(store, slug), formatFloor(store, floor), direction, formatFloor(store, ema.Value))
+	}
+	ema.Above = above
+	ema.Deviated = deviated
+	ema.Anomalous = anomalous
+	return msg
+}
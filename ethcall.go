@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+)
+
+// EthCallConfig reads the floor from a contract
+type EthCallConfig struct {
+	Contract string `json:"contract"`
+	// abi encoded calldata starting with the method selector.
+	// {{slug}} is replaced by the slug as a 32 byte word e.g. a collection address or token id in hex
+	Data string `json:"data"`
+	// the returned uint256 is divided by 10^decimals
+	Decimals int `json:"decimals"`
+	// defaults to latest
+	Block string `json:"block"`
+}
+
+// ethCall calls the contract through the rpc at url and decodes the first returned word
+func ethCall(client *http.Client, url, slug string, config EthCallConfig) (float64, error) {
+	word := strings.TrimPrefix(slug, "0x")
+	if len(word) < 64 {
+		word = strings.Repeat("0", 64-len(word)) + word
+	}
+	data := strings.ReplaceAll(config.Data, "{{slug}}", strings.ToLower(word))
+	if !strings.HasPrefix(data, "0x") {
+		data = "0x" + data
+	}
+	block := config.Block
+	if block == "" {
+		block = "latest"
+	}
+	payload, err := json.Marshal(map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      1,
+		"method":  "eth_call",
+		"params":  []interface{}{map[string]string{"to": config.Contract, "data": data}, block},
+	})
+	if err != nil {
+		return 0, err
+	}
+	res, err := client.Post(url, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+	var response struct {
+		Result string `json:"result"`
+		Error  *struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	err = json.NewDecoder(res.Body).Decode(&response)
+	if err != nil {
+		return 0, fmt.Errorf("eth_call: %w", err)
+	}
+	if response.Error != nil {
+		return 0, fmt.Errorf("eth_call: %s", response.Error.Message)
+	}
+	result, err := hex.DecodeString(strings.TrimPrefix(response.Result, "0x"))
+	if err != nil || len(result) < 32 {
+		return 0, fmt.Errorf("eth_call: invalid result %q", response.Result)
+	}
+	value := new(big.Float).SetInt(new(big.Int).SetBytes(result[:32]))
+	scale := new(big.Float).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(config.Decimals)), nil))
+	floor, _ := new(big.Float).Quo(value, scale).Float64()
+	return floor, nil
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+// healthyBot remembers which bot token last delivered a message so later sends start with it
+type healthyBot struct {
+	mu    sync.Mutex
+	index int
+}
+
+// the token sendMessage tries first. Shared by every TelegramConfig since only the main one has fallbacks
+var currentBot healthyBot
+
+func (h *healthyBot) get() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.index
+}
+
+func (h *healthyBot) set(index int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.index = index
+}
+
+// botIDs are bot_id followed by fallback_bot_ids
+func (t TelegramConfig) botIDs() []string {
+	return append([]string{t.BotID}, t.FallbackBotIDs...)
+}
+
+// botEndpoint is endpoint for a specific bot token
+func (t TelegramConfig) botEndpoint(token, method string) string {
+	return fmt.Sprintf("%s/bot%s/%s", t.APIURL, token, method)
+}
+
+// persistentFailure is true for responses another token may not get:
+// a revoked or banned token, rate limiting that outlasted the retries or a server error.
+// Other errors such as an unknown chat or a malformed message would fail with any token
+func persistentFailure(status int) bool {
+	switch status {
+	case http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound, http.StatusTooManyRequests:
+		return true
+	}
+	return status >= http.StatusInternalServerError
+}
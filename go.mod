@@ -1,3 +1,5 @@
 module github.com/enzosv/nftfloorbot
 
 go 1.16
+
+require github.com/itchyny/gojq v0.12.8
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// HeartbeatConfig proves the bot is still running to an outside monitor.
+// Sent after a cycle completes so a hung loop stops it too
+type HeartbeatConfig struct {
+	Minutes float64 `json:"every_minutes"`
+	// pinged with the cycle summary as the body e.g. a healthchecks.io check url
+	URL string `json:"url"`
+	// also send "still alive" with the cycle summary to telegram.operator_id
+	Message bool `json:"message"`
+}
+
+// heartbeatDue returns true at most once per interval. Not persisted so a restart beats right away
+func (s *State) heartbeatDue(now time.Time, interval time.Duration) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if now.Sub(s.lastHeartbeat) < interval {
+		return false
+	}
+	s.lastHeartbeat = now
+	return true
+}
+
+// heartbeat pings heartbeat.url and messages the operator if the interval passed
+func (c *cycle) heartbeat(summary string) {
+	heartbeat := c.config.Heartbeat
+	interval := time.Duration(heartbeat.Minutes * float64(time.Minute))
+	if interval <= 0 || !c.state.heartbeatDue(c.deps.Clock.Now(), interval) {
+		return
+	}
+	if heartbeat.URL != "" {
+		res, err := c.deps.Client.Post(heartbeat.URL, "text/plain", strings.NewReader(summary))
+		if err != nil {
+			fmt.Printf("heartbeat: %v\n", err)
+		} else {
+			res.Body.Close()
+			if res.StatusCode >= 300 {
+				fmt.Printf("heartbeat: %s\n", res.Status)
+			}
+		}
+	}
+	if heartbeat.Message {
+		notifyOperator(c.deps, c.config, "still alive. last cycle "+summary)
+	}
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// selectorPart is one compound selector such as div.stats or [data-id=floor]
+type selectorPart struct {
+	tag     string
+	id      string
+	classes []string
+	attrs   map[string]string
+}
+
+// parseSelector supports tags, #id, .class and [attr=value] compounds
+// combined by descendant spaces, the subset needed to point at a price
+func parseSelector(selector string) ([]selectorPart, error) {
+	var parts []selectorPart
+	for _, compound := range strings.Fields(selector) {
+		part := selectorPart{attrs: map[string]string{}}
+		for compound != "" {
+			end := strings.IndexAny(compound[1:], ".#[") + 1
+			if end == 0 {
+				end = len(compound)
+			}
+			token := compound[:end]
+			switch token[0] {
+			case '#':
+				part.id = token[1:]
+			case '.':
+				part.classes = append(part.classes, token[1:])
+			case '[':
+				end = strings.IndexByte(compound, ']')
+				if end < 0 {
+					return nil, fmt.Errorf("unclosed [ in selector %q", selector)
+				}
+				token = compound[:end]
+				end++
+				kv := strings.SplitN(token[1:], "=", 2)
+				value := ""
+				if len(kv) == 2 {
+					value = strings.Trim(kv[1], `"'`)
+				}
+				part.attrs[kv[0]] = value
+			default:
+				part.tag = strings.ToLower(token)
+			}
+			compound = compound[end:]
+		}
+		parts = append(parts, part)
+	}
+	if len(parts) == 0 {
+		return nil, fmt.Errorf("empty selector")
+	}
+	return parts, nil
+}
+
+func (p selectorPart) matches(element xml.StartElement) bool {
+	if p.tag != "" && p.tag != strings.ToLower(element.Name.Local) {
+		return false
+	}
+	attrs := map[string]string{}
+	for _, attr := range element.Attr {
+		attrs[strings.ToLower(attr.Name.Local)] = attr.Value
+	}
+	if p.id != "" && attrs["id"] != p.id {
+		return false
+	}
+	classes := strings.Fields(attrs["class"])
+	for _, class := range p.classes {
+		if !containsString(classes, class) {
+			return false
+		}
+	}
+	for name, value := range p.attrs {
+		actual, ok := attrs[strings.ToLower(name)]
+		if !ok || (value != "" && actual != value) {
+			return false
+		}
+	}
+	return true
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesPath returns true if the last part matches the last element of path
+// and the other parts match its ancestors in order
+func matchesPath(parts []selectorPart, path []xml.StartElement) bool {
+	if !parts[len(parts)-1].matches(path[len(path)-1]) {
+		return false
+	}
+	i := len(parts) - 2
+	for j := len(path) - 2; j >= 0 && i >= 0; j-- {
+		if parts[i].matches(path[j]) {
+			i--
+		}
+	}
+	return i < 0
+}
+
+// scripts and styles are not markup and trip the xml decoder
+var unparsedElements = regexp.MustCompile(`(?is)<script\b.*?</script\s*>|<style\b.*?</style\s*>|<!--.*?-->`)
+
+// selectHTML returns the text of the first element of page matching selector.
+// The page is read leniently as html by the xml decoder
+func selectHTML(page []byte, selector string) (string, error) {
+	parts, err := parseSelector(selector)
+	if err != nil {
+		return "", err
+	}
+	dec := xml.NewDecoder(bytes.NewReader(unparsedElements.ReplaceAll(page, nil)))
+	dec.Strict = false
+	dec.AutoClose = xml.HTMLAutoClose
+	dec.Entity = xml.HTMLEntity
+	var path []xml.StartElement
+	// depth of path at the matched element while reading its text
+	matched := -1
+	var text strings.Builder
+	for {
+		token, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", fmt.Errorf("html: %w", err)
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			path = append(path, t.Copy())
+			if matched < 0 && matchesPath(parts, path) {
+				matched = len(path)
+			}
+		case xml.EndElement:
+			if matched == len(path) {
+				return strings.TrimSpace(text.String()), nil
+			}
+			if len(path) > 0 {
+				path = path[:len(path)-1]
+			}
+		case xml.CharData:
+			if matched >= 0 {
+				text.Write(t)
+			}
+		}
+	}
+	return "", fmt.Errorf("no element matches %q", selector)
+}
+
+// parsePrice reads the number in text such as "Floor: Ξ1,234.5"
+func parsePrice(text string) (float64, error) {
+	start := strings.IndexAny(text, "0123456789")
+	if start < 0 {
+		return 0, fmt.Errorf("no number in %q", text)
+	}
+	end := start
+	for end < len(text) && strings.IndexByte("0123456789.,", text[end]) >= 0 {
+		end++
+	}
+	return strconv.ParseFloat(strings.ReplaceAll(text[start:end], ",", ""), 64)
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+type InfluxConfig struct {
+	// InfluxDB v2 base url. Leave empty to disable
+	URL    string `json:"url"`
+	Org    string `json:"org"`
+	Bucket string `json:"bucket"`
+	Token  string `json:"token"`
+}
+
+var tagEscaper = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `)
+
+// writeInflux sends all floors as a single batch of line protocol points
+func writeInflux(config InfluxConfig, floors map[string]float64, date time.Time) error {
+	var lines []string
+	for slug, floor := range floors {
+		lines = append(lines, fmt.Sprintf("floor,slug=%s value=%v %d", tagEscaper.Replace(slug), floor, date.Unix()))
+	}
+	query := url.Values{}
+	query.Set("org", config.Org)
+	query.Set("bucket", config.Bucket)
+	query.Set("precision", "s")
+	endpoint := fmt.Sprintf("%s/api/v2/write?%s", strings.TrimSuffix(config.URL, "/"), query.Encode())
+	req, err := http.NewRequest("POST", endpoint, strings.NewReader(strings.Join(lines, "\n")))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Token "+config.Token)
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("influxdb: %w", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusNoContent {
+		body, _ := ioutil.ReadAll(res.Body)
+		return fmt.Errorf("influxdb: %s %s", res.Status, body)
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"sync"
+
+	"github.com/itchyny/gojq"
+)
+
+// compiled jq filters by source
+var (
+	jqMu    sync.Mutex
+	jqCache = map[string]*gojq.Code{}
+)
+
+func compileJQ(filter string) (*gojq.Code, error) {
+	jqMu.Lock()
+	defer jqMu.Unlock()
+	if code, ok := jqCache[filter]; ok {
+		return code, nil
+	}
+	query, err := gojq.Parse(filter)
+	if err != nil {
+		return nil, err
+	}
+	code, err := gojq.Compile(query)
+	if err != nil {
+		return nil, err
+	}
+	jqCache[filter] = code
+	return code, nil
+}
+
+// evalJQ runs filter against the decoded response and returns its first output as a number
+func evalJQ(filter string, root interface{}) (float64, error) {
+	code, err := compileJQ(filter)
+	if err != nil {
+		return 0, fmt.Errorf("jq %q: %w", filter, err)
+	}
+	result, ok := code.Run(root).Next()
+	if !ok {
+		return 0, fmt.Errorf("jq %q: no output", filter)
+	}
+	switch val := result.(type) {
+	case error:
+		return 0, fmt.Errorf("jq %q: %w", filter, val)
+	case float64:
+		return val, nil
+	case int:
+		return float64(val), nil
+	case *big.Int:
+		floor, _ := new(big.Float).SetInt(val).Float64()
+		return floor, nil
+	default:
+		return 0, fmt.Errorf("jq %q: ended with %v", filter, val)
+	}
+}
+
+// evalJQExact is evalJQ returning the number as a big.Float
+// so big integers such as wei amounts reach high_precision scaling exactly
+func evalJQExact(filter string, root interface{}) (*big.Float, error) {
+	code, err := compileJQ(filter)
+	if err != nil {
+		return nil, fmt.Errorf("jq %q: %w", filter, err)
+	}
+	result, ok := code.Run(root).Next()
+	if !ok {
+		return nil, fmt.Errorf("jq %q: no output", filter)
+	}
+	switch val := result.(type) {
+	case error:
+		return nil, fmt.Errorf("jq %q: %w", filter, val)
+	case float64:
+		if math.IsNaN(val) {
+			return nil, fmt.Errorf("jq %q: ended with %v", filter, val)
+		}
+		return new(big.Float).SetPrec(precisionBits).SetFloat64(val), nil
+	case int:
+		return new(big.Float).SetPrec(precisionBits).SetInt64(int64(val)), nil
+	case *big.Int:
+		return new(big.Float).SetPrec(precisionBits).SetInt(val), nil
+	default:
+		return nil, fmt.Errorf("jq %q: ended with %v", filter, val)
+	}
+}
@@ -0,0 +1,525 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock is a Clock tests move by hand
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// fakeMarket serves the floors of slugs in the shapes of the test stores:
+// /nested/<slug> as stats.floor_price, /list as a list of every slug,
+// /string/<slug> and /null/<slug> as floor
+type fakeMarket struct {
+	*httptest.Server
+	mu     sync.Mutex
+	floors map[string]interface{}
+}
+
+func newFakeMarket(floors map[string]interface{}) *fakeMarket {
+	m := &fakeMarket{floors: floors}
+	m.Server = httptest.NewServer(http.HandlerFunc(m.serve))
+	return m
+}
+
+func (m *fakeMarket) set(slug string, floor interface{}) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.floors[slug] = floor
+}
+
+func (m *fakeMarket) serve(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	parts := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/"), "/", 2)
+	var body interface{}
+	switch {
+	case parts[0] == "list":
+		var list []map[string]interface{}
+		for slug, floor := range m.floors {
+			list = append(list, map[string]interface{}{"slug": slug, "floor": floor})
+		}
+		body = list
+	case len(parts) == 2 && parts[0] == "nested":
+		body = map[string]interface{}{"stats": map[string]interface{}{"floor_price": m.floors[parts[1]]}}
+	case len(parts) == 2:
+		body = map[string]interface{}{"floor": m.floors[parts[1]]}
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	json.NewEncoder(w).Encode(body)
+}
+
+// fakeTelegram records the messages sent through the bot api
+type fakeTelegram struct {
+	*httptest.Server
+	mu       sync.Mutex
+	messages []sentMessage
+	// called with each message before it is recorded. See onSend
+	hook func(sentMessage)
+}
+
+type sentMessage struct {
+	ChatID string `json:"chat_id"`
+	Text   string `json:"text"`
+}
+
+func newFakeTelegram() *fakeTelegram {
+	f := &fakeTelegram{}
+	f.Server = httptest.NewServer(http.HandlerFunc(f.serve))
+	return f
+}
+
+func (f *fakeTelegram) serve(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasSuffix(r.URL.Path, "/sendMessage") {
+		http.NotFound(w, r)
+		return
+	}
+	var message sentMessage
+	json.NewDecoder(r.Body).Decode(&message)
+	f.mu.Lock()
+	hook := f.hook
+	f.mu.Unlock()
+	if hook != nil {
+		hook(message)
+	}
+	f.mu.Lock()
+	f.messages = append(f.messages, message)
+	id := len(f.messages)
+	f.mu.Unlock()
+	json.NewEncoder(w).Encode(map[string]interface{}{"ok": true, "result": map[string]interface{}{"message_id": id}})
+}
+
+// onSend calls hook on the server's goroutine with each message before it is recorded
+func (f *fakeTelegram) onSend(hook func(sentMessage)) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.hook = hook
+}
+
+func (f *fakeTelegram) sent() []sentMessage {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]sentMessage(nil), f.messages...)
+}
+
+// testBot is a config and deps reaching a fake market and telegram.
+// Tests may change both before starting the bot with them
+type testBot struct {
+	market   *fakeMarket
+	telegram *fakeTelegram
+	clock    *fakeClock
+	config   Config
+	deps     Deps
+}
+
+// newTestBot watches apes through a nested json_map, cats and dogs through a list,
+// wei as a string and void, which never has a floor
+func newTestBot(t *testing.T, floors map[string]interface{}) *testBot {
+	t.Helper()
+	b := &testBot{market: newFakeMarket(floors), telegram: newFakeTelegram()}
+	t.Cleanup(b.market.Close)
+	t.Cleanup(b.telegram.Close)
+	dir := t.TempDir()
+	content, err := json.Marshal(map[string]interface{}{
+		"history_json_path": filepath.Join(dir, "history.json"),
+		"state_json_path":   filepath.Join(dir, "state.json"),
+		"telegram": map[string]interface{}{
+			"bot_id":       "token",
+			"recipient_id": "42",
+			"api_url":      b.telegram.URL,
+		},
+		"stores": []map[string]interface{}{
+			{"stats_url": b.market.URL + "/nested/%s", "json_map": []string{"stats", "floor_price"}, "collection_slugs": []string{"apes"}, "max": 100},
+			{"stats_url": b.market.URL + "/list", "list_match_field": "slug", "json_map": []string{"floor"}, "collection_slugs": []string{"cats", "dogs"}, "max": 100},
+			{"stats_url": b.market.URL + "/string/%s", "json_map": []string{"floor"}, "collection_slugs": []string{"wei"}, "max": 100},
+			{"stats_url": b.market.URL + "/null/%s", "json_map": []string{"floor"}, "collection_slugs": []string{"void"}, "max": 100},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "config.json")
+	err = ioutil.WriteFile(path, content, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// read like a config file so defaults apply
+	b.config = parseConfig(path)
+	b.deps, err = newDeps(b.config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.clock = &fakeClock{now: time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)}
+	b.deps.Clock = b.clock
+	return b
+}
+
+// start loads state and history like a starting bot and seeds the floors missing from it
+func (b *testBot) start(t *testing.T) (*State, *FloorStore) {
+	t.Helper()
+	state, err := loadState(b.config.StatePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// missing on the first start
+	floors, _ := openFloorStore(b.deps.History, b.config.HistoryFormat, time.Duration(b.config.PersistInterval*float64(time.Second)), b.config.CompactHistory)
+	seedFloors(b.deps, b.config, floors)
+	return state, floors
+}
+
+// cycle runs watchFloor a minute after the last one
+func (b *testBot) cycle(state *State, floors *FloorStore) {
+	b.clock.advance(time.Minute)
+	watchFloor(b.deps, b.config, state, floors)
+}
+
+// latestFloors reads the latest floor of each slug from the history file
+func (b *testBot) latestFloors(t *testing.T) map[string]float64 {
+	t.Helper()
+	history, err := readFloor(fileBlob(b.config.Output), b.config.HistoryFormat)
+	if err != nil {
+		t.Fatal(err)
+	}
+	latest := map[string]float64{}
+	for _, persisted := range history {
+		latest[persisted.Slug] = persisted.Floor
+	}
+	return latest
+}
+
+// latestOf returns the last floor of slug in history
+func latestOf(history []Persisted, slug string) (float64, bool) {
+	for i := len(history) - 1; i >= 0; i-- {
+		if history[i].Slug == slug {
+			return history[i].Floor, true
+		}
+	}
+	return 0, false
+}
+
+func defaultFloors() map[string]interface{} {
+	return map[string]interface{}{"apes": 10.0, "cats": 2.0, "dogs": 3.0, "wei": "5.5", "void": nil}
+}
+
+func TestWatchFloor(t *testing.T) {
+	b := newTestBot(t, defaultFloors())
+	state, floors := b.start(t)
+	if sent := b.telegram.sent(); len(sent) != 0 {
+		t.Fatalf("seeding sent %v", sent)
+	}
+	b.market.set("apes", 8.0)
+	b.market.set("dogs", 4.0)
+	b.market.set("wei", "6")
+	b.cycle(state, floors)
+
+	sent := b.telegram.sent()
+	if len(sent) != 1 {
+		t.Fatalf("sent %d messages, want 1: %v", len(sent), sent)
+	}
+	if sent[0].ChatID != "42" {
+		t.Errorf("sent to %s, want 42", sent[0].ChatID)
+	}
+	for _, slug := range []string{"apes", "dogs", "wei"} {
+		if !strings.Contains(sent[0].Text, slug) {
+			t.Errorf("alert %q does not mention %s", sent[0].Text, slug)
+		}
+	}
+	for _, slug := range []string{"cats", "void"} {
+		if strings.Contains(sent[0].Text, slug) {
+			t.Errorf("alert %q mentions unchanged %s", sent[0].Text, slug)
+		}
+	}
+
+	want := map[string]float64{"apes": 8, "cats": 2, "dogs": 4, "wei": 6}
+	latest := b.latestFloors(t)
+	if len(latest) != len(want) {
+		t.Errorf("history has %v, want %v", latest, want)
+	}
+	for slug, floor := range want {
+		if latest[slug] != floor {
+			t.Errorf("history floor of %s is %v, want %v", slug, latest[slug], floor)
+		}
+	}
+}
+
+// TestCrashAfterSend restarts the bot right after an alert was sent, as if it crashed
+// before anything else ran. The history must already hold the alerted floor
+// so the restarted bot does not alert on the same change again
+func TestCrashAfterSend(t *testing.T) {
+	b := newTestBot(t, defaultFloors())
+	state, floors := b.start(t)
+	var persisted []Persisted
+	b.telegram.onSend(func(sentMessage) {
+		// t.Fatal must not be called from the server's goroutine
+		persisted, _ = readFloor(fileBlob(b.config.Output), b.config.HistoryFormat)
+	})
+	b.market.set("apes", 8.0)
+	b.cycle(state, floors)
+	if len(b.telegram.sent()) != 1 {
+		t.Fatalf("sent %v, want one alert", b.telegram.sent())
+	}
+	if latest, ok := latestOf(persisted, "apes"); !ok || latest != 8 {
+		t.Fatalf("history had apes at %v when the alert was sent, want 8", latest)
+	}
+
+	// the crashed process saves nothing more. a new one starts from the files
+	state, floors = b.start(t)
+	b.cycle(state, floors)
+	if sent := b.telegram.sent(); len(sent) != 1 {
+		t.Errorf("restart alerted again: %v", sent[1:])
+	}
+}
+
+func TestSameFloor(t *testing.T) {
+	// variables so the sums are computed in float64 instead of as exact constants
+	tenth, fifth, multiplier := 0.1, 0.2, 1e18
+	if tenth+fifth == 0.3 {
+		t.Fatal("0.1+0.2 is exactly 0.3")
+	}
+	tests := []struct {
+		a, b float64
+		same bool
+	}{
+		{tenth + fifth, 0.3, true},
+		{0.3, tenth + fifth, true},
+		{(tenth + fifth) * multiplier, 0.3 * multiplier, true},
+		{(tenth + fifth) / multiplier, 0.3 / multiplier, true},
+		{0, 0, true},
+		{0.3, 0.3001, false},
+		{0.3 * multiplier, 0.3001 * multiplier, false},
+		{0, 1e-12, false},
+	}
+	for _, test := range tests {
+		if got := sameFloor(test.a, test.b); got != test.same {
+			t.Errorf("sameFloor(%v, %v) = %v, want %v", test.a, test.b, got, test.same)
+		}
+	}
+}
+
+func TestStoreSameFloor(t *testing.T) {
+	tests := []struct {
+		name      string
+		tolerance float64
+		epsilon   float64
+		old       float64
+		floor     float64
+		same      bool
+	}{
+		{"equal", 0, 0, 10, 10, true},
+		{"tiny change", 0, 0, 0.3, 0.3000001, false},
+		{"change without tolerance", 0, 0, 10, 10.01, false},
+		{"within tolerance", 0.001, 0, 10, 10.005, true},
+		{"within tolerance below", 0.001, 0, 10, 9.995, true},
+		{"beyond tolerance", 0.001, 0, 10, 10.02, false},
+		{"tolerance is of old", 0.1, 0, 10, 11, true},
+		{"within epsilon", 0, 0.05, 10, 10.04, true},
+		{"beyond epsilon", 0, 0.05, 10, 10.06, false},
+		{"either is enough", 0.001, 0.05, 10, 10.04, true},
+		{"beyond both", 0.001, 0.05, 10, 10.2, false},
+	}
+	for _, test := range tests {
+		store := StoreConfig{EqualityTolerance: test.tolerance, EqualityEpsilon: test.epsilon}
+		if got := store.sameFloor(test.old, test.floor); got != test.same {
+			t.Errorf("%s: sameFloor(%v, %v) = %v, want %v", test.name, test.old, test.floor, got, test.same)
+		}
+	}
+}
+
+func TestSameFloorCycle(t *testing.T) {
+	tenth, fifth := 0.1, 0.2
+	floors := defaultFloors()
+	floors["apes"] = 0.3
+	b := newTestBot(t, floors)
+	state, history := b.start(t)
+	b.market.set("apes", tenth+fifth)
+	b.cycle(state, history)
+	if sent := b.telegram.sent(); len(sent) != 0 {
+		t.Errorf("alerted %v on a rounding difference", sent)
+	}
+	if floor := b.latestFloors(t)["apes"]; floor != 0.3 {
+		t.Errorf("history floor of apes is %v, want 0.3", floor)
+	}
+}
+
+func TestStoreJQ(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		valid  bool
+	}{
+		{"valid", `{"jq": ".stats.floor", "currency_variants": [{"name": "usd", "jq": ".stats.usd"}]}`, true},
+		{"bad jq", `{"jq": ".stats[", "max": 100}`, false},
+		{"bad variant jq", `{"jq": ".stats.floor", "currency_variants": [{"name": "usd", "jq": ".stats | nope("}]}`, false},
+	}
+	for _, test := range tests {
+		var store StoreConfig
+		err := json.Unmarshal([]byte(test.config), &store)
+		if (err == nil) != test.valid {
+			t.Errorf("%s: got error %v, want valid %v", test.name, err, test.valid)
+		}
+	}
+}
+
+func TestExactFloor(t *testing.T) {
+	raw, err := decodeNumbers([]byte(`{"floor_wei": 123456789012345678901}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// rounding the amount, the division and the multiplication separately gives 135.80246791358027
+	const want = 135.80246791358024
+	for _, config := range []string{
+		`{"json_map": ["floor_wei"], "amount_decimals": 18, "multiplier": 1.1, "high_precision": true}`,
+		`{"jq": ".floor_wei", "amount_decimals": 18, "multiplier": 1.1, "high_precision": true}`,
+	} {
+		var store StoreConfig
+		err := json.Unmarshal([]byte(config), &store)
+		if err != nil {
+			t.Fatal(err)
+		}
+		floor, err := exactFloor(store, raw, "")
+		if err != nil {
+			t.Errorf("%s: %v", config, err)
+			continue
+		}
+		if floor != want {
+			t.Errorf("%s: floor is %v, want %v", config, floor, want)
+		}
+	}
+}
+
+func TestSetThreshold(t *testing.T) {
+	b := newTestBot(t, defaultFloors())
+	state, _ := b.start(t)
+	ctx := commandContext{deps: b.deps, config: b.config, state: state}
+	tests := []struct {
+		field string
+		value string
+		valid bool
+	}{
+		{"max", "20", true},
+		{"max", "-1", false},
+		{"min", "-0.5", false},
+		{"min", "NaN", false},
+		{"max", "Inf", false},
+		{"change", "-5", true},
+		{"change", "+Inf", false},
+		{"change", "nan", false},
+	}
+	for _, test := range tests {
+		_, err := setThreshold(test.field)(ctx, []string{"apes", test.value})
+		if (err == nil) != test.valid {
+			t.Errorf("set%s %s: got error %v, want valid %v", test.field, test.value, err, test.valid)
+		}
+	}
+}
+
+// TestCooldownAfterUnmute mutes a slug while it moves. The muted alert must not start
+// the cooldown, so the slug alerts again as soon as it is unmuted
+func TestCooldownAfterUnmute(t *testing.T) {
+	b := newTestBot(t, defaultFloors())
+	b.config.Stores[0].Cooldown = 60
+	state, floors := b.start(t)
+	err := state.silence("apes", time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.market.set("apes", 8.0)
+	b.cycle(state, floors)
+	if sent := b.telegram.sent(); len(sent) != 0 {
+		t.Fatalf("alerted %v while muted", sent)
+	}
+
+	err = state.unsilence("apes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.market.set("apes", 6.0)
+	b.cycle(state, floors)
+	sent := b.telegram.sent()
+	if len(sent) != 1 || !strings.Contains(sent[0].Text, "apes") {
+		t.Fatalf("sent %v after unmuting, want the apes alert", sent)
+	}
+
+	// the delivered alert starts the cooldown
+	b.market.set("apes", 4.0)
+	b.cycle(state, floors)
+	if sent := b.telegram.sent(); len(sent) != 1 {
+		t.Errorf("alerted %v during the cooldown", sent[1:])
+	}
+}
+
+// failingBlob is a blob whose writes fail while failing is set
+type failingBlob struct {
+	blob
+	mu      sync.Mutex
+	failing bool
+}
+
+func (f *failingBlob) write(content []byte) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.failing {
+		return errors.New("disk full")
+	}
+	return f.blob.write(content)
+}
+
+func (f *failingBlob) fail(failing bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.failing = failing
+}
+
+func TestRollbackFailedSave(t *testing.T) {
+	b := newTestBot(t, defaultFloors())
+	b.config.RollbackFailedSaves = true
+	history := &failingBlob{blob: b.deps.History}
+	b.deps.History = history
+	state, floors := b.start(t)
+
+	history.fail(true)
+	b.market.set("apes", 8.0)
+	b.cycle(state, floors)
+	if floor := floors.Get("apes"); floor != 10 {
+		t.Fatalf("baseline of apes after a failed save is %v, want the saved 10", floor)
+	}
+	if floor := b.latestFloors(t)["apes"]; floor != 10 {
+		t.Fatalf("history floor of apes is %v, want 10", floor)
+	}
+
+	history.fail(false)
+	b.cycle(state, floors)
+	if floor := b.latestFloors(t)["apes"]; floor != 8 {
+		t.Errorf("history floor of apes after a successful save is %v, want 8", floor)
+	}
+	// the change is alerted again once it is persisted
+	if sent := b.telegram.sent(); len(sent) != 2 {
+		t.Errorf("sent %d alerts, want 2: %v", len(sent), sent)
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import "fmt"
+
+// checkMarketWide replaces the cycle's alerts with one summary when more than
+// config.MarketWide percent of the watched slugs alerted on their floor
+func (c *cycle) checkMarketWide() {
+	if c.config.MarketWide <= 0 {
+		return
+	}
+	watched := 0
+	for _, store := range c.config.Stores {
+		if store.Enabled {
+			watched += len(store.Slugs)
+		}
+	}
+	moved := map[string]float64{}
+	for _, alerts := range c.alerts {
+		for _, alert := range alerts {
+			if alert.Metric == "" {
+				moved[alert.Slug] = alert.Change
+			}
+		}
+	}
+	if watched == 0 || float64(len(moved))*100/float64(watched) <= c.config.MarketWide {
+		return
+	}
+	sum := 0.0
+	for _, change := range moved {
+		sum += change
+	}
+	average := sum / float64(len(moved))
+	count := 0
+	for _, alerts := range c.alerts {
+		count += len(alerts)
+	}
+	for i := 0; i < count; i++ {
+		c.state.suppress(suppressedMarketWide)
+	}
+	recipient := c.config.Telegram.RecipientID
+	c.alerts = map[string][]Alert{recipient: {{
+		Recipient: recipient,
+		Change:    average,
+		Date:      c.deps.Clock.Now(),
+		Message:   fmt.Sprintf("market-wide move: %d of %d collections changed, avg %+.2f%%", len(moved), watched, average),
+	}}}
+}
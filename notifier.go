@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// Notifier delivers alert messages to a recipient.
+// alerts are the ones message was composed from. Sends stop once ctx is done
+type Notifier interface {
+	Send(ctx context.Context, recipient, message string, alerts []Alert) error
+}
+
+// telegramNotifier sends through the bot api
+type telegramNotifier struct {
+	client *http.Client
+	config TelegramConfig
+	sent   *sentMessages
+}
+
+func (t telegramNotifier) Send(ctx context.Context, recipient, message string, alerts []Alert) error {
+	var markup interface{}
+	if t.config.AlertButtons {
+		markup = alertButtons(alerts)
+	}
+	var replyTo int64
+	if len(alerts) > 0 {
+		replyTo = alerts[0].replyTo
+	}
+	sent, err := sendReply(ctx, t.client, t.config, recipient, message, markup, replyTo)
+	if err != nil && replyTo != 0 && anchorGone(err) {
+		return fmt.Errorf("%w: %v", errAnchorGone, err)
+	}
+	if err != nil {
+		return err
+	}
+	t.sent.record(sent, alerts)
+	return nil
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// mockNotifier records what it is asked to send and fails with err
+type mockNotifier struct {
+	mu    sync.Mutex
+	err   error
+	calls []mockSend
+}
+
+type mockSend struct {
+	ctx       context.Context
+	recipient string
+	message   string
+	alerts    []Alert
+}
+
+func (m *mockNotifier) Send(ctx context.Context, recipient, message string, alerts []Alert) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.calls = append(m.calls, mockSend{ctx, recipient, message, alerts})
+	return m.err
+}
+
+func TestSendAlertsNotifiers(t *testing.T) {
+	b := newTestBot(t, defaultFloors())
+	b.config.AlertLog = filepath.Join(t.TempDir(), "alerts.jsonl")
+	state, err := loadState(filepath.Join(t.TempDir(), "state.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	working, failing := &mockNotifier{}, &mockNotifier{err: errors.New("down")}
+	alert := newAlert(b.config.Stores[0], "42", "apes", "", 8, 10, b.clock.Now())
+	logged := func() int {
+		data, _ := ioutil.ReadFile(b.config.AlertLog)
+		return strings.Count(string(data), "\n")
+	}
+
+	b.deps.Notifiers = []Notifier{failing}
+	sendAlerts(b.deps, b.config, state, "42", []Alert{alert})
+	if len(failing.calls) != 1 {
+		t.Fatalf("sent %d times, want 1", len(failing.calls))
+	}
+	if n := logged(); n != 0 {
+		t.Errorf("logged %d undelivered alerts", n)
+	}
+
+	b.deps.Notifiers = []Notifier{failing, working}
+	sendAlerts(b.deps, b.config, state, "42", []Alert{alert})
+	if len(working.calls) != 1 {
+		t.Fatalf("sent %d times, want 1", len(working.calls))
+	}
+	call := working.calls[0]
+	if call.ctx == nil || call.recipient != "42" || !strings.Contains(call.message, "apes") || len(call.alerts) != 1 {
+		t.Errorf("sent %+v", call)
+	}
+	if n := logged(); n != 1 {
+		t.Errorf("logged %d alerts, want 1 once any notifier succeeds", n)
+	}
+	if sent := b.telegram.sent(); len(sent) != 0 {
+		t.Errorf("telegram got %v despite mock notifiers", sent)
+	}
+}
+
+func TestTelegramNotifierCancelled(t *testing.T) {
+	b := newTestBot(t, defaultFloors())
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := b.deps.Notifiers[0].Send(ctx, "42", "apes 8", nil)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Send returned %v, want context.Canceled", err)
+	}
+	if sent := b.telegram.sent(); len(sent) != 0 {
+		t.Errorf("telegram got %v after cancel", sent)
+	}
+}
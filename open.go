@@ -0,0 +1,53 @@
+package main
+
+import (
+	"time"
+)
+
+// Open is the first floor of a slug observed since the day started
+type Open struct {
+	Floor float64   `json:"floor"`
+	Date  time.Time `json:"date"`
+}
+
+// dayStart returns when the day containing now started at config.DayStart in now's location
+func (c Config) dayStart(now time.Time) time.Time {
+	boundary, _ := time.Parse(scheduleLayout, c.DayStart)
+	start := time.Date(now.Year(), now.Month(), now.Day(), boundary.Hour(), boundary.Minute(), 0, 0, now.Location())
+	if now.Before(start) {
+		start = start.AddDate(0, 0, -1)
+	}
+	return start
+}
+
+// recordOpen keeps floor as the open of slug if it is the first one since dayStart
+func (s *State) recordOpen(slug string, floor float64, now, dayStart time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if open, ok := s.Opens[slug]; ok && !open.Date.Before(dayStart) {
+		return
+	}
+	s.Opens[slug] = Open{floor, now}
+	s.dirty = true
+}
+
+// opens returns the open floor by slug of opens recorded since dayStart
+func (s *State) opens(dayStart time.Time) map[string]float64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	opens := map[string]float64{}
+	for slug, open := range s.Opens {
+		if !open.Date.Before(dayStart) {
+			opens[slug] = open.Floor
+		}
+	}
+	return opens
+}
+
+// changeSinceOpen formats the change from open to floor
+func changeSinceOpen(store StoreConfig, open, floor float64) string {
+	if open == 0 {
+		return "n/a"
+	}
+	return formatPercent(store, (floor-open)/open*100)
+}
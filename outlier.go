@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// suspectOutlier returns true if floor is more than outlier_factor times above or below
+// the median floor of slug over the last outlier_hours and was not already suspected last cycle.
+// A suspected floor is alerted as such and skipped so garbage ticks never become the baseline.
+// It is accepted once the next cycle is still that far from the median
+func (c *cycle) suspectOutlier(store StoreConfig, slug string, floor float64) bool {
+	now := c.deps.Clock.Now()
+	recent := c.floors.Recent(slug, now.Add(-time.Duration(store.OutlierHours*float64(time.Hour))))
+	if len(recent) == 0 {
+		return false
+	}
+	floors := make([]float64, len(recent))
+	for i, persisted := range recent {
+		floors[i] = persisted.Floor
+	}
+	sort.Float64s(floors)
+	median := floors[len(floors)/2]
+	if len(floors)%2 == 0 {
+		median = (floors[len(floors)/2-1] + floors[len(floors)/2]) / 2
+	}
+	outlier := median > 0 && (floor > median*store.OutlierFactor || floor < median/store.OutlierFactor)
+	if !c.state.markSuspect(slug, outlier) {
+		return false
+	}
+	alert := newAlert(store, c.recipient(store), slug, "outlier", floor, median, now)
+	alert.Message = fmt.Sprintf("suspected outlier: %s %s is more than %s times away from its median %s. Ignored unless it persists next cycle", slugLink(store, slug), formatFloor(store, floor), strconv.FormatFloat(store.OutlierFactor, 'f', -1, 64), formatFloor(store, median))
+	c.queue(alert)
+	return true
+}
+
+// markSuspect returns true if an outlier of slug is new. A second outlier in a row is confirmed
+func (s *State) markSuspect(slug string, outlier bool) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !outlier {
+		delete(s.suspects, slug)
+		return false
+	}
+	if s.suspects[slug] {
+		delete(s.suspects, slug)
+		return false
+	}
+	s.suspects[slug] = true
+	return true
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"regexp"
+)
+
+// matchSlugs returns the list_match_field of every element of the store's list
+// matching slug_pattern that is not already one of its collection_slugs
+func (c *cycle) matchSlugs(store StoreConfig) ([]string, error) {
+	pattern, err := regexp.Compile(store.SlugPattern)
+	if err != nil {
+		return nil, err
+	}
+	url := statsURL(store, "")
+	res, err := requestStats(c.deps.Stats, url, "", store)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", url, err)
+	}
+	defer res.Body.Close()
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", url, err)
+	}
+	raw, err := decodeResponse(store.Format, body)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", url, err)
+	}
+	list, ok := raw.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s: expected a list of collections. Root is %T", url, raw)
+	}
+	var slugs []string
+	for _, element := range list {
+		object, ok := element.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		value, ok := object[store.MatchField]
+		if !ok {
+			continue
+		}
+		slug := fmt.Sprint(value)
+		if pattern.MatchString(slug) && !containsString(store.Slugs, slug) && !containsString(slugs, slug) {
+			slugs = append(slugs, slug)
+		}
+	}
+	return slugs, nil
+}
+
+// watchMatches watches the slugs currently matching slug_pattern.
+// Like slugs newly added to the config, the first floor of a new match is only recorded
+func (c *cycle) watchMatches(store StoreConfig) {
+	slugs, err := c.matchSlugs(store)
+	if err != nil {
+		fmt.Println(err)
+		c.mu.Lock()
+		c.errors++
+		c.mu.Unlock()
+		return
+	}
+	store.SlugPattern = ""
+	store.Slugs = slugs
+	store.SkipNew = true
+	c.watchSlugs(store)
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// fewest earlier floors in the window for a percentile to mean anything
+const minPercentileSamples = 10
+
+// checkPercentile alerts when the latest floor of slug enters the bottom percentile_below
+// or the top percentile_above percent of the floors recorded over percentile_days.
+// It alerts once on entering, not while the floor stays there
+func (c *cycle) checkPercentile(store StoreConfig, slug string) {
+	now := c.deps.Clock.Now()
+	recent := c.floors.Recent(slug, now.Add(-time.Duration(store.PercentileDays*24*float64(time.Hour))))
+	if len(recent) < minPercentileSamples+1 {
+		return
+	}
+	samples := recent[:len(recent)-1]
+	last, previous := recent[len(recent)-1].Floor, recent[len(recent)-2].Floor
+	rank := func(floor float64) float64 {
+		below := 0
+		for _, sample := range samples {
+			if sample.Floor < floor {
+				below++
+			}
+		}
+		return float64(below) * 100 / float64(len(samples))
+	}
+	now_rank, previous_rank := rank(last), rank(previous)
+	var message string
+	switch {
+	case store.PercentileBelow > 0 && now_rank < store.PercentileBelow && previous_rank >= store.PercentileBelow:
+		message = fmt.Sprintf("%s at %s is in the bottom %v%% of the last %v days", slugLink(store, slug), formatFloor(store, last), store.PercentileBelow, store.PercentileDays)
+	case store.PercentileAbove > 0 && now_rank > 100-store.PercentileAbove && previous_rank <= 100-store.PercentileAbove:
+		message = fmt.Sprintf("%s at %s is in the top %v%% of the last %v days", slugLink(store, slug), formatFloor(store, last), store.PercentileAbove, store.PercentileDays)
+	default:
+		return
+	}
+	alert := newAlert(store, c.recipient(store), slug, "percentile", last, previous, now)
+	alert.Message = message
+	c.queue(alert)
+}
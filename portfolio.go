@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// portfolioSummary values holdings at the latest floors, in each store currency and in usd if rates are known.
+// Each holding has its floor's change over the last 7 and 30 days
+func portfolioSummary(config Config, floors *FloorStore, rates, opens map[string]float64, now time.Time) string {
+	history := floors.History()
+	var slugs []string
+	for slug := range config.Holdings {
+		slugs = append(slugs, slug)
+	}
+	sort.Strings(slugs)
+	lines := []string{"portfolio:"}
+	totals := map[string]float64{}
+	var currencies []string
+	usd := 0.0
+	priced := true
+	for _, slug := range slugs {
+		quantity := config.Holdings[slug]
+		store, ok := storeOf(config, slug)
+		floor := floors.Get(slug)
+		if !ok || floor == 0 {
+			lines = append(lines, fmt.Sprintf("%s: no floor yet", slug))
+			continue
+		}
+		value := floor * quantity
+		today := ""
+		if store.ShowOpen {
+			today = "today " + changeSinceOpen(store, opens[slug], floor) + ", "
+		}
+		lines = append(lines, fmt.Sprintf("%s: %v × %s = %s (%s7d %s, 30d %s)", slugLink(store, slug), quantity, formatFloor(store, floor), formatFloor(store, value),
+			today, changeSince(store, history, slug, floor, now.AddDate(0, 0, -7)), changeSince(store, history, slug, floor, now.AddDate(0, 0, -30))))
+		if _, ok := totals[store.Currency]; !ok {
+			currencies = append(currencies, store.Currency)
+		}
+		totals[store.Currency] += value
+		if rate := rates[store.Currency]; rate > 0 {
+			usd += value * rate
+		} else {
+			priced = false
+		}
+	}
+	for _, currency := range currencies {
+		label := currency
+		if label == "" {
+			label = "native"
+		}
+		lines = append(lines, fmt.Sprintf("total %s: %v", label, formatNumber(totals[currency], 4, 0, false)))
+	}
+	if priced && len(totals) > 0 {
+		lines = append(lines, "total usd: $"+formatNumber(usd, 2, 0, true))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// storeOf returns the store watching slug
+func storeOf(config Config, slug string) (StoreConfig, bool) {
+	for _, store := range config.Stores {
+		for _, s := range store.Slugs {
+			if s == slug {
+				return store, true
+			}
+		}
+	}
+	return StoreConfig{}, false
+}
+
+// sendPortfolio sends the summary to the recipient every portfolio_every_hours
+func (c *cycle) sendPortfolio() {
+	window := time.Duration(c.config.PortfolioHours * float64(time.Hour))
+	if window <= 0 || len(c.config.Holdings) == 0 || !c.state.portfolioDue(c.deps.Clock.Now(), window) {
+		return
+	}
+	err := sendMessage(c.deps.Telegram, c.config.Telegram, c.config.Telegram.RecipientID, portfolioSummary(c.config, c.floors, c.rates, c.state.opens(c.config.dayStart(c.deps.Clock.Now())), c.deps.Clock.Now()))
+	if err != nil {
+		fmt.Println(err)
+	}
+}
+
+func portfolioCommand(ctx commandContext, args []string) (string, error) {
+	if len(ctx.config.Holdings) == 0 {
+		return "no holdings configured", nil
+	}
+	rates, err := ctx.deps.Rates.get(ctx.deps.Client, ctx.deps.Clock.Now(), currencies(ctx.config.Stores))
+	if err != nil {
+		fmt.Println(err)
+	}
+	return portfolioSummary(ctx.config, ctx.floors, rates, ctx.state.opens(ctx.config.dayStart(ctx.deps.Clock.Now())), ctx.deps.Clock.Now()), nil
+}
+
+// changeSince formats the change from the floor of slug at date to floor
+// or n/a if the history of slug starts after date
+func changeSince(store StoreConfig, history []Persisted, slug string, floor float64, date time.Time) string {
+	then, ok := floorAt(history, slug, date)
+	if !ok || then == 0 {
+		return "n/a"
+	}
+	return formatPercent(store, (floor-then)/then*100)
+}
+
+// floorAt returns the floor of slug at date, the last one recorded at or before it
+func floorAt(history []Persisted, slug string, date time.Time) (float64, bool) {
+	floor, found := 0.0, false
+	for _, persisted := range history {
+		if persisted.Slug != slug || persisted.Date.After(date) {
+			continue
+		}
+		floor, found = persisted.Floor, true
+	}
+	return floor, found
+}
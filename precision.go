@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// bits of the big.Float high_precision floors are scaled in
+const precisionBits = 256
+
+// decodeNumbers decodes a json response keeping numbers as json.Number
+// so high_precision floors keep every digit until they are scaled
+func decodeNumbers(body []byte) (interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var raw interface{}
+	err := decoder.Decode(&raw)
+	return raw, err
+}
+
+// traverseExact is traverse returning the number as a big.Float
+func traverseExact(root interface{}, tree []string) (*big.Float, error) {
+	stats, ok := root.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid json traverse. Root is %T", root)
+	}
+	for _, key := range tree {
+		switch val := stats[key].(type) {
+		case json.Number:
+			return parseExact(string(val))
+		case string:
+			return parseExact(strings.TrimSpace(val))
+		case float64:
+			return new(big.Float).SetPrec(precisionBits).SetFloat64(val), nil
+		case map[string]interface{}:
+			stats = val
+		default:
+			return nil, fmt.Errorf("invalid json traverse. Ended with %v", val)
+		}
+	}
+	return nil, fmt.Errorf("not found")
+}
+
+func parseExact(text string) (*big.Float, error) {
+	value, _, err := big.ParseFloat(text, 10, precisionBits, big.ToNearestEven)
+	if err != nil {
+		return nil, fmt.Errorf("invalid json traverse. Ended with %q", text)
+	}
+	return value, nil
+}
+
+// exactFloor is the floor from jq, or at json_map or its fallbacks, scaled by decimals and scaleFloor
+// in big.Float so it is rounded to float64 once
+func exactFloor(store StoreConfig, raw interface{}, symbol string) (float64, error) {
+	value, err := exactValue(store, raw)
+	if err != nil {
+		return 0, err
+	}
+	scale := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(store.decimals(symbol))), nil)
+	value.Quo(value, new(big.Float).SetPrec(precisionBits).SetInt(scale))
+	if store.Invert {
+		if value.Sign() == 0 {
+			return 0, fmt.Errorf("cannot invert a floor of 0")
+		}
+		value.Quo(new(big.Float).SetPrec(precisionBits).SetInt64(1), value)
+	}
+	value.Mul(value, new(big.Float).SetPrec(precisionBits).SetFloat64(store.Multiplier))
+	floor, _ := value.Float64()
+	return floor, nil
+}
+
+// exactValue is the unscaled floor from the store's jq or json_map and its fallbacks
+func exactValue(store StoreConfig, raw interface{}) (*big.Float, error) {
+	if store.JQ != "" {
+		value, err := evalJQExact(store.JQ, raw)
+		if err != nil {
+			return nil, fmt.Errorf("floor %w", err)
+		}
+		return value, nil
+	}
+	var value *big.Float
+	var first error
+	for i, tree := range append([][]string{store.Tree}, store.FallbackTrees...) {
+		var err error
+		value, err = traverseExact(raw, tree)
+		if err == nil {
+			if i > 0 {
+				fmt.Printf("json_map %v failed. using fallback %v\n", store.Tree, tree)
+			}
+			break
+		}
+		if i == 0 {
+			first = err
+		}
+	}
+	if value == nil {
+		return nil, fmt.Errorf("floor %w", first)
+	}
+	return value, nil
+}
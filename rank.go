@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// checkRank records the position of slug in its store's list as <slug>/rank
+// and alerts when it moved rank_change places or crossed rank_top since the last one
+func (c *cycle) checkRank(store StoreConfig, slug string, rank int) {
+	key := metricKey(slug, "rank")
+	previous := int(c.floors.Get(key))
+	if previous == rank {
+		return
+	}
+	now := c.deps.Clock.Now()
+	c.floors.Set(key, float64(rank), now)
+	if previous == 0 {
+		// no baseline yet
+		return
+	}
+	var reasons []string
+	if store.RankTop > 0 && rank <= store.RankTop && previous > store.RankTop {
+		reasons = append(reasons, fmt.Sprintf("entered top %d", store.RankTop))
+	}
+	if store.RankTop > 0 && rank > store.RankTop && previous <= store.RankTop {
+		reasons = append(reasons, fmt.Sprintf("left top %d", store.RankTop))
+	}
+	if store.RankChange > 0 && abs(rank-previous) >= store.RankChange {
+		reasons = append(reasons, fmt.Sprintf("moved %d places", abs(rank-previous)))
+	}
+	if len(reasons) == 0 {
+		c.state.suppress(suppressedChange)
+		return
+	}
+	alert := newAlert(store, c.recipient(store), slug, "rank", float64(rank), float64(previous), now)
+	alert.Message = fmt.Sprintf("%s rank %d → %d (%s)", slugLink(store, slug), previous, rank, strings.Join(reasons, ", "))
+	c.queue(alert)
+}
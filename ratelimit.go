@@ -0,0 +1,92 @@
+package main
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// tokenBucket limits outgoing messages. A nil bucket allows everything
+type tokenBucket struct {
+	mu       sync.Mutex
+	rate     float64
+	capacity float64
+	tokens   float64
+	last     time.Time
+}
+
+// limits telegram sends. Set from telegram.max_messages_per_second
+var sendLimiter *tokenBucket
+
+func newTokenBucket(rate float64) *tokenBucket {
+	if rate <= 0 {
+		return nil
+	}
+	capacity := rate
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &tokenBucket{rate: rate, capacity: capacity, tokens: capacity, last: time.Now()}
+}
+
+func (b *tokenBucket) refill() {
+	now := time.Now()
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.capacity {
+		b.tokens = b.capacity
+	}
+	b.last = now
+}
+
+// allow takes a token if one is available
+func (b *tokenBucket) allow() bool {
+	if b == nil {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.refill()
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// wait blocks until a token is available and takes it
+func (b *tokenBucket) wait() {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.refill()
+	if b.tokens < 1 {
+		time.Sleep(time.Duration((1 - b.tokens) / b.rate * float64(time.Second)))
+		b.refill()
+	}
+	b.tokens--
+}
+
+// hostLimiter spaces out requests to each host to stay within its requests per minute
+type hostLimiter struct {
+	next    http.RoundTripper
+	buckets map[string]*tokenBucket
+}
+
+// newHostLimiter wraps next with a budget per host. Hosts without a budget are not limited
+func newHostLimiter(next http.RoundTripper, perMinute map[string]float64) http.RoundTripper {
+	if len(perMinute) == 0 {
+		return next
+	}
+	buckets := map[string]*tokenBucket{}
+	for host, rpm := range perMinute {
+		buckets[host] = newTokenBucket(rpm / 60)
+	}
+	return &hostLimiter{next: next, buckets: buckets}
+}
+
+func (l *hostLimiter) RoundTrip(req *http.Request) (*http.Response, error) {
+	l.buckets[req.URL.Hostname()].wait()
+	return l.next.RoundTrip(req)
+}
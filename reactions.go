@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MessageReaction is a change of the reactions to a message
+type MessageReaction struct {
+	Chat struct {
+		ID       int64  `json:"id"`
+		Username string `json:"username"`
+	} `json:"chat"`
+	MessageID   int64 `json:"message_id"`
+	NewReaction []struct {
+		Type  string `json:"type"`
+		Emoji string `json:"emoji"`
+	} `json:"new_reaction"`
+}
+
+// most alert messages remembered for reactions
+const maxSentMessages = 1000
+
+// sentMessages remembers the slugs of recent alert messages
+// so reactions to a message can silence them. Lost on restart
+type sentMessages struct {
+	mu    sync.Mutex
+	slugs map[string][]string
+	// keys oldest first
+	order []string
+}
+
+func newSentMessages() *sentMessages {
+	return &sentMessages{slugs: map[string][]string{}}
+}
+
+func messageKey(chat, id int64) string {
+	return fmt.Sprintf("%d:%d", chat, id)
+}
+
+// record remembers the slugs of alerts as those of the message
+func (s *sentMessages) record(message *Message, alerts []Alert) {
+	var slugs []string
+	seen := map[string]bool{}
+	for _, alert := range alerts {
+		if alert.Slug != "" && !seen[alert.Slug] {
+			seen[alert.Slug] = true
+			slugs = append(slugs, alert.Slug)
+		}
+	}
+	if message == nil || len(slugs) == 0 {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := messageKey(message.Chat.ID, message.MessageID)
+	if _, ok := s.slugs[key]; !ok {
+		s.order = append(s.order, key)
+	}
+	s.slugs[key] = slugs
+	if len(s.order) > maxSentMessages {
+		delete(s.slugs, s.order[0])
+		s.order = s.order[1:]
+	}
+}
+
+// lookup returns the slugs of the message
+func (s *sentMessages) lookup(chat, id int64) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.slugs[messageKey(chat, id)]
+}
+
+// handleReaction silences the slugs of the reacted to alert by telegram.reactions
+// and returns the confirmation. Empty if the reaction has no action
+func handleReaction(ctx commandContext, reaction *MessageReaction) string {
+	hours, ok := 0.0, false
+	for _, r := range reaction.NewReaction {
+		if hours, ok = ctx.config.Telegram.Reactions[r.Emoji]; ok {
+			break
+		}
+	}
+	if !ok {
+		return ""
+	}
+	slugs := ctx.deps.Sent.lookup(reaction.Chat.ID, reaction.MessageID)
+	if len(slugs) == 0 {
+		return ""
+	}
+	var until time.Time
+	if hours > 0 {
+		until = ctx.deps.Clock.Now().Add(time.Duration(hours * float64(time.Hour)))
+	}
+	for _, slug := range slugs {
+		err := ctx.state.silence(slug, until)
+		if err != nil {
+			return fmt.Sprintf("could not save: %v", err)
+		}
+	}
+	if until.IsZero() {
+		return fmt.Sprintf("%s muted. /unmute to undo", strings.Join(slugs, ", "))
+	}
+	return fmt.Sprintf("%s snoozed until %s", strings.Join(slugs, ", "), until.Format("2006-01-02 15:04"))
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// checkRecovery alerts when the latest floor of slug is recovery_change percent above
+// its lowest floor of the last recovery_hours. It alerts once when the floor first gets there
+func (c *cycle) checkRecovery(store StoreConfig, slug string) {
+	now := c.deps.Clock.Now()
+	recent := c.floors.Recent(slug, now.Add(-time.Duration(store.RecoveryHours*float64(time.Hour))))
+	if len(recent) < 2 {
+		return
+	}
+	low := recent[0].Floor
+	for _, persisted := range recent[:len(recent)-1] {
+		if persisted.Floor < low {
+			low = persisted.Floor
+		}
+	}
+	last, previous := recent[len(recent)-1].Floor, recent[len(recent)-2].Floor
+	if low <= 0 || (last-low)/low*100 < store.RecoveryChange || (previous-low)/low*100 >= store.RecoveryChange {
+		return
+	}
+	alert := newAlert(store, c.recipient(store), slug, "recovery", last, low, now)
+	alert.Change = (last - low) / low * 100
+	alert.Message = fmt.Sprintf("recovering: %s %s → %s since its %vh low (%s)", slugLink(store, slug), formatFloor(store, low), formatFloor(store, last), store.RecoveryHours, formatPercent(store, alert.Change))
+	c.queue(alert)
+}
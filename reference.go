@@ -0,0 +1,23 @@
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+// checkReference alerts when the floor moves from within reference_change percent of reference to beyond it
+func (c *cycle) checkReference(store StoreConfig, slug string, reference, old_floor, floor float64) {
+	if old_floor == 0 || store.ReferenceChange <= 0 {
+		// no baseline to tell if the floor just crossed
+		return
+	}
+	change := (floor - reference) / reference * 100
+	previous := (old_floor - reference) / reference * 100
+	if math.Abs(change) < store.ReferenceChange || math.Abs(previous) >= store.ReferenceChange {
+		return
+	}
+	alert := newAlert(store, c.recipient(store), slug, "reference", floor, reference, c.deps.Clock.Now())
+	alert.Change = change
+	alert.Message = fmt.Sprintf("%s: %s is %s from reference %s", slugLink(store, slug), formatFloor(store, floor), formatPercent(store, change), formatFloor(store, reference))
+	c.queue(alert)
+}
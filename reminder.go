@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// slide is a floor alerted on that may keep moving the same way
+type slide struct {
+	// floor of the alert
+	alerted float64
+	falling bool
+	// floor and time of the alert or the last reminder
+	last     float64
+	reminded time.Time
+}
+
+// startSlide tracks the floor alert of slug for reminders
+func (s *State) startSlide(slug string, alert Alert) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.slides[slug] = &slide{alerted: alert.Floor, falling: alert.Floor < alert.OldFloor, last: alert.Floor, reminded: alert.Date}
+}
+
+// nextReminder returns the slide of slug if every has passed since its last reminder
+// and floor moved further the alerted way since. A slide that stopped or reversed is dropped
+func (s *State) nextReminder(slug string, floor float64, now time.Time, every time.Duration) (slide, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	current, ok := s.slides[slug]
+	if !ok || now.Sub(current.reminded) < every {
+		return slide{}, false
+	}
+	further := floor > current.last
+	if current.falling {
+		further = floor < current.last
+	}
+	if !further || sameFloor(floor, current.last) {
+		delete(s.slides, slug)
+		return slide{}, false
+	}
+	current.last = floor
+	current.reminded = now
+	return *current, true
+}
+
+// remind sends how far the floor of slug went since its alert every reminder_minutes
+// while it keeps going that way
+func (c *cycle) remind(store StoreConfig, slug string, floor float64) {
+	now := c.deps.Clock.Now()
+	current, ok := c.state.nextReminder(slug, floor, now, time.Duration(store.ReminderMinutes*float64(time.Minute)))
+	if !ok {
+		return
+	}
+	moving := "still rising"
+	if current.falling {
+		moving = "still falling"
+	}
+	alert := newAlert(store, c.recipient(store), slug, "reminder", floor, current.alerted, now)
+	alert.Message = fmt.Sprintf("%s: %s now %s, %s from the alert at %s", moving, slugLink(store, slug), formatFloor(store, floor), formatPercent(store, alert.Change), formatFloor(store, current.alerted))
+	c.queue(alert)
+}
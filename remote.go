@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// RemoteConfig is a key in etcd or consul holding {"stores": [...]} that replaces the stores
+// of the config files while it is set. It is read every poll_seconds and applies from the next cycle.
+// Other settings build clients at startup so they still come from the files
+type RemoteConfig struct {
+	// etcd or consul
+	Source string `json:"source"`
+	// e.g. http://127.0.0.1:2379 for etcd or http://127.0.0.1:8500 for consul
+	URL string `json:"url"`
+	Key string `json:"key"`
+	// sent as X-Consul-Token to consul and as Authorization to etcd
+	Token string `json:"token"`
+	// defaults to 30
+	PollSeconds float64 `json:"poll_seconds"`
+}
+
+// remoteStores is the value of the remote key
+type remoteStores struct {
+	Stores []StoreConfig `json:"stores"`
+}
+
+// readRemote returns the value of the remote key or nil if it is not set
+func readRemote(client *http.Client, remote RemoteConfig) ([]byte, error) {
+	base := strings.TrimSuffix(remote.URL, "/")
+	var req *http.Request
+	var err error
+	if remote.Source == "etcd" {
+		body, _ := json.Marshal(map[string]string{"key": base64.StdEncoding.EncodeToString([]byte(remote.Key))})
+		req, err = http.NewRequest("POST", base+"/v3/kv/range", bytes.NewReader(body))
+		if err == nil && remote.Token != "" {
+			req.Header.Set("Authorization", remote.Token)
+		}
+	} else {
+		req, err = http.NewRequest("GET", base+"/v1/kv/"+strings.TrimPrefix(remote.Key, "/")+"?raw", nil)
+		if err == nil && remote.Token != "" {
+			req.Header.Set("X-Consul-Token", remote.Token)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	content, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode == http.StatusNotFound && remote.Source == "consul" {
+		return nil, nil
+	}
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s %s", res.Status, content)
+	}
+	if remote.Source == "consul" {
+		return content, nil
+	}
+	var response struct {
+		KVs []struct {
+			Value string `json:"value"`
+		} `json:"kvs"`
+	}
+	err = json.Unmarshal(content, &response)
+	if err != nil || len(response.KVs) == 0 {
+		return nil, err
+	}
+	return base64.StdEncoding.DecodeString(response.KVs[0].Value)
+}
+
+// parseRemote decodes and validates the stores of a remote value
+// the way the config files are, without exiting on errors
+func parseRemote(content []byte) ([]StoreConfig, error) {
+	var remote remoteStores
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&remote); err != nil {
+		return nil, err
+	}
+	if len(remote.Stores) == 0 {
+		return nil, fmt.Errorf("no stores")
+	}
+	for _, store := range remote.Stores {
+		if store.StatsURL == "" && store.EthCall.Contract == "" {
+			return nil, fmt.Errorf("store %s has no stats_url", store.label())
+		}
+		if store.SlugsFile != "" {
+			return nil, fmt.Errorf("store %s: collection_slugs_file is only read from config files", store.label())
+		}
+	}
+	return remote.Stores, nil
+}
+
+// applyRemote replaces the stores of config with the last valid remote stores,
+// reading the remote key again once poll_seconds passed. A failed read or invalid
+// value keeps the last valid stores
+func (w *watcher) applyRemote(config Config) Config {
+	remote := config.Remote
+	if remote.URL == "" {
+		return config
+	}
+	now := time.Now()
+	if now.Sub(w.remoteRead) >= time.Duration(remote.PollSeconds*float64(time.Second)) {
+		w.remoteRead = now
+		content, err := readRemote(w.deps.Client, remote)
+		if err != nil {
+			fmt.Printf("remote config %s: %v\n", remote.Key, err)
+		} else if content == nil {
+			w.remoteStores = nil
+		} else if stores, err := parseRemote(content); err != nil {
+			fmt.Printf("remote config %s is invalid. keeping the last valid one: %v\n", remote.Key, err)
+		} else {
+			w.remoteStores = stores
+		}
+	}
+	if w.remoteStores != nil {
+		config.Stores = w.remoteStores
+	}
+	return config
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// delay before the first retry of a failed fetch. It doubles with each retry
+const fetchRetryDelay = 500 * time.Millisecond
+
+// retryable returns true if a response with status should be fetched again.
+// Without retry_statuses only rate limiting and server errors are, so a 404 fails fast
+func (s StoreConfig) retryable(status int) bool {
+	if len(s.RetryStatuses) == 0 {
+		return status == http.StatusTooManyRequests || status >= http.StatusInternalServerError
+	}
+	for _, retry := range s.RetryStatuses {
+		if retry == status {
+			return true
+		}
+	}
+	return false
+}
+
+// requestStats sends the store's stats request for slug, retrying up to fetch_retries times
+// on network errors and retryable statuses. Other responses are returned as they are
+func requestStats(client *http.Client, url, slug string, store StoreConfig) (*http.Response, error) {
+	delay := fetchRetryDelay
+	for attempt := 0; ; attempt++ {
+		// built again each attempt for a fresh signature timestamp
+		req, err := newStatsRequest(url, slug, store)
+		if err != nil {
+			return nil, err
+		}
+		res, err := client.Do(req)
+		if attempt == store.FetchRetries || (err == nil && !store.retryable(res.StatusCode)) {
+			return res, err
+		}
+		if err == nil {
+			res.Body.Close()
+			err = fmt.Errorf("%s", res.Status)
+		}
+		fmt.Printf("%s: %v. retrying in %v\n", url, err, delay)
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ScheduleConfig limits when alerts are sent. Times are in config.Timezone
+type ScheduleConfig struct {
+	// alerts are sent only within these. None to always send
+	Windows []ScheduleWindow `json:"windows"`
+	// buffer to send alerts outside the windows once one opens or drop to discard them. Defaults to drop
+	Policy string `json:"outside"`
+}
+
+// ScheduleWindow is a daily time range. A to before from ends the next day
+type ScheduleWindow struct {
+	// e.g. mon, tue. None for every day
+	Days []string `json:"days"`
+	From string   `json:"from"`
+	To   string   `json:"to"`
+}
+
+const scheduleLayout = "15:04"
+
+// validate returns an error for windows with unknown days or times
+func (s ScheduleConfig) validate() error {
+	if s.Policy != "" && s.Policy != "buffer" && s.Policy != "drop" {
+		return fmt.Errorf("unknown outside policy %q", s.Policy)
+	}
+	for _, window := range s.Windows {
+		for _, day := range window.Days {
+			if weekday(day) < 0 {
+				return fmt.Errorf("unknown day %q", day)
+			}
+		}
+		for _, clock := range []string{window.From, window.To} {
+			if _, err := time.Parse(scheduleLayout, clock); err != nil {
+				return fmt.Errorf("invalid time %q", clock)
+			}
+		}
+	}
+	return nil
+}
+
+func weekday(day string) time.Weekday {
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		if strings.EqualFold(day, d.String()[:3]) {
+			return d
+		}
+	}
+	return -1
+}
+
+// open returns true if date is within a window or there are none
+func (s ScheduleConfig) open(date time.Time) bool {
+	if len(s.Windows) == 0 {
+		return true
+	}
+	minute := date.Hour()*60 + date.Minute()
+	for _, window := range s.Windows {
+		from, _ := time.Parse(scheduleLayout, window.From)
+		to, _ := time.Parse(scheduleLayout, window.To)
+		start, end := from.Hour()*60+from.Minute(), to.Hour()*60+to.Minute()
+		day := date.Weekday()
+		var within bool
+		if start <= end {
+			within = minute >= start && minute < end
+		} else if minute >= start {
+			within = true
+		} else if minute < end {
+			// the part after midnight belongs to the window of the day before
+			within = true
+			day = (day + 6) % 7
+		}
+		if within && onDay(window.Days, day) {
+			return true
+		}
+	}
+	return false
+}
+
+func onDay(days []string, day time.Weekday) bool {
+	if len(days) == 0 {
+		return true
+	}
+	for _, d := range days {
+		if weekday(d) == day {
+			return true
+		}
+	}
+	return false
+}
+
+// applySchedule holds back the cycle's alerts outside the schedule
+// and adds the buffered ones once it is open
+func (c *cycle) applySchedule() {
+	schedule := c.config.Schedule
+	if schedule.open(c.deps.Clock.Now()) {
+		for recipient, alerts := range c.state.releaseDeferred() {
+			c.alerts[recipient] = append(alerts, c.alerts[recipient]...)
+		}
+		return
+	}
+	for recipient, alerts := range c.alerts {
+		if schedule.Policy == "buffer" {
+			c.state.deferAlerts(recipient, alerts)
+			continue
+		}
+		for range alerts {
+			c.state.suppress(suppressedSchedule)
+		}
+	}
+	c.alerts = map[string][]Alert{}
+}
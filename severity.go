@@ -0,0 +1,33 @@
+package main
+
+import (
+	"math"
+	"strings"
+)
+
+// Severity rewords alerts that moved at least above percent either way
+type Severity struct {
+	Above float64 `json:"above"`
+	// {{message}} is the alert's message. {{slug}}, {{floor}}, {{old_floor}} and {{change}} are also replaced
+	Template string `json:"template"`
+}
+
+// severe rewrites the message of alert with the template of the highest severity its change reaches
+func (s StoreConfig) severe(alert Alert) Alert {
+	// sorted by above when the config was loaded
+	for i := len(s.Severities) - 1; i >= 0; i-- {
+		severity := s.Severities[i]
+		if math.Abs(alert.Change) < severity.Above {
+			continue
+		}
+		alert.Message = strings.NewReplacer(
+			"{{message}}", alert.Message,
+			"{{slug}}", alert.Slug,
+			"{{floor}}", formatFloor(s, alert.Floor),
+			"{{old_floor}}", formatFloor(s, alert.OldFloor),
+			"{{change}}", formatPercent(s, alert.Change),
+		).Replace(severity.Template)
+		return alert
+	}
+	return alert
+}
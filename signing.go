@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// SigningConfig signs stats requests with an hmac of
+// timestamp + method + path with query + body, as exchange apis expect
+type SigningConfig struct {
+	// sha256 or sha512. Defaults to sha256
+	Algorithm string `json:"algorithm"`
+	Secret    string `json:"secret"`
+	// header the signature is sent in
+	Header string `json:"header"`
+	// header the unix timestamp is sent in
+	TimestampHeader string `json:"timestamp_header"`
+	// send the timestamp in milliseconds instead of seconds
+	Milliseconds bool `json:"timestamp_ms"`
+	// hex or base64. Defaults to hex
+	Encoding string `json:"encoding"`
+}
+
+func (s SigningConfig) validate() error {
+	if s.Header == "" {
+		return fmt.Errorf("signing needs a header")
+	}
+	if s.Algorithm != "" && s.Algorithm != "sha256" && s.Algorithm != "sha512" {
+		return fmt.Errorf("unknown signing algorithm %q", s.Algorithm)
+	}
+	if s.Encoding != "" && s.Encoding != "hex" && s.Encoding != "base64" {
+		return fmt.Errorf("unknown signing encoding %q", s.Encoding)
+	}
+	return nil
+}
+
+// sign adds the timestamp and signature headers to req. The body is read and restored
+func (s SigningConfig) sign(req *http.Request, now time.Time) error {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = ioutil.ReadAll(req.Body)
+		if err != nil {
+			return err
+		}
+		req.Body.Close()
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	if s.Milliseconds {
+		timestamp = strconv.FormatInt(now.UnixNano()/int64(time.Millisecond), 10)
+	}
+	algorithm := sha256.New
+	if s.Algorithm == "sha512" {
+		algorithm = sha512.New
+	}
+	mac := hmac.New(algorithm, []byte(s.Secret))
+	mac.Write([]byte(timestamp + req.Method + req.URL.RequestURI()))
+	mac.Write(body)
+	signature := hex.EncodeToString(mac.Sum(nil))
+	if s.Encoding == "base64" {
+		signature = base64.StdEncoding.EncodeToString(mac.Sum(nil))
+	}
+	req.Header.Set(s.Header, signature)
+	if s.TimestampHeader != "" {
+		req.Header.Set(s.TimestampHeader, timestamp)
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// socketBroadcaster streams alerts as json lines to every client connected to a unix socket.
+// A nil broadcaster drops everything
+type socketBroadcaster struct {
+	mu    sync.Mutex
+	conns map[net.Conn]bool
+}
+
+func listenSocket(path string) (*socketBroadcaster, error) {
+	// remove the socket left behind by a previous run
+	os.Remove(path)
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	b := &socketBroadcaster{conns: map[net.Conn]bool{}}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				fmt.Println("socket:", err)
+				return
+			}
+			b.mu.Lock()
+			b.conns[conn] = true
+			b.mu.Unlock()
+		}
+	}()
+	return b, nil
+}
+
+// publish writes alerts to all clients, dropping those that are gone or too slow
+func (b *socketBroadcaster) publish(alerts []Alert) {
+	if b == nil || len(alerts) == 0 {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for conn := range b.conns {
+		conn.SetWriteDeadline(time.Now().Add(time.Second))
+		enc := json.NewEncoder(conn)
+		for _, alert := range alerts {
+			if err := enc.Encode(alert); err != nil {
+				conn.Close()
+				delete(b.conns, conn)
+				break
+			}
+		}
+	}
+}
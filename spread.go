@@ -0,0 +1,36 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// checkSpread records the percent the spread_metric, e.g. the top bid, is below the floor
+// and alerts when it narrows below spread_below. The spread is saved to history as <slug>/spread
+func (c *cycle) checkSpread(store StoreConfig, slug string, floor float64, stats Stats) {
+	bid, ok := stats.Metrics[store.SpreadMetric]
+	if !ok || floor == 0 {
+		return
+	}
+	key := metricKey(slug, "spread")
+	spread := (floor - bid) / floor * 100
+	previous := c.floors.Get(key)
+	if previous != 0 && sameFloor(previous, spread) {
+		return
+	}
+	now := c.deps.Clock.Now()
+	c.floors.Set(key, spread, now)
+	if spread >= store.SpreadBelow || (previous != 0 && previous < store.SpreadBelow) {
+		// only alert when the spread crosses the threshold
+		return
+	}
+	cooldown := time.Duration(store.SpreadCooldown * float64(time.Minute))
+	if cooldown > 0 && c.state.coolingDown(key, now, cooldown, false) {
+		c.state.suppress(suppressedCooldown)
+		return
+	}
+	alert := newAlert(store, c.recipient(store), slug, "spread", spread, previous, now)
+	alert.cooldown = cooldown > 0
+	alert.Message = fmt.Sprintf("%s spread narrowed to %.2f%%: floor %s, %s %s", slugLink(store, slug), spread, formatFloor(store, floor), store.SpreadMetric, formatFloor(store, bid))
+	c.queue(alert)
+}
@@ -0,0 +1,579 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// State is runtime state shared across cycles and commands.
+// Exported fields are persisted to survive restarts
+type State struct {
+	mu   sync.RWMutex
+	path string
+	// changed since the last save
+	dirty bool
+	// per slug thresholds set through telegram commands
+	Overrides map[string]*Override `json:"overrides"`
+	EMA       map[string]*EMA      `json:"ema"`
+	// last alert per slug for cooldowns
+	Alerted map[string]time.Time `json:"alerted"`
+	// slugs not alerted until the time or at all through the alert buttons.
+	// Keyed by slug so every metric of the slug is silenced
+	Snoozed map[string]time.Time `json:"snoozed"`
+	Muted   map[string]bool      `json:"muted"`
+	// last scheduled portfolio summary
+	PortfolioSent time.Time `json:"portfolio_sent"`
+	// slugs added to stores by label and removed from every store through /watch and /unwatch
+	Watched   map[string][]string `json:"watched"`
+	Unwatched map[string]bool     `json:"unwatched"`
+	// first floor of each slug since the day started
+	Opens map[string]Open `json:"opens"`
+	// alerts no notifier delivered by recipient. Retried every cycle
+	Undelivered map[string][]undeliveredAlert `json:"undelivered"`
+	// floors of the alerts delivered within restart_dedup_minutes by metric key
+	Recent map[string]recentAlert `json:"recent_alerts"`
+	// alerts delivered per slug since the state was created
+	AlertCounts map[string]int `json:"alert_counts"`
+	// message the alerts of each slug reply to by "<recipient> <slug>"
+	Anchors map[string]int64 `json:"anchors"`
+	// whether the slug of each cross was below its other slug at the last cycle, by slug<other
+	Below map[string]bool `json:"below"`
+
+	// not persisted. A restart restarts the staleness window
+	lastSuccess   map[string]time.Time
+	staleNotified map[string]bool
+	// consecutive failed fetches and the last time they were reported
+	failures      map[string]int
+	errorNotified map[string]time.Time
+	// slugs whose unchanged floor was already reported
+	frozenNotified map[string]bool
+	// alerts held until their aggregation window ends by metric key.
+	// Not persisted either so a restart drops them
+	held map[string]*heldAlert
+	// alerts not sent since start by reason
+	suppressed map[string]int
+	// cycles run since start
+	cycles int
+	// fetches since start by store label
+	fetches map[string]*fetchStats
+	// alerts outside the schedule by recipient. Lost on restart
+	deferred map[string][]Alert
+	// hash of the last message sent per recipient
+	lastSent map[string][sha256.Size]byte
+	// slugs whose last floor was skipped as a suspected outlier
+	suspects map[string]bool
+	// last heartbeat sent. Zero after a restart
+	lastHeartbeat time.Time
+	// floor alerts reminded of by slug. A restart stops their reminders
+	slides map[string]*slide
+	// when the watcher started by deps.Clock
+	started time.Time
+	// latest fetch of each slug for /debug
+	responses map[string]lastResponse
+}
+
+// reasons an alert is suppressed
+const (
+	suppressedChange    = "below-change-threshold"
+	suppressedCooldown  = "in-cooldown"
+	suppressedBand      = "outside-min-max"
+	suppressedDirection = "wrong-direction"
+	suppressedVolume    = "low-volume"
+	suppressedWarmup    = "warm-up"
+	suppressedMuted     = "muted"
+	suppressedSchedule  = "outside-schedule"
+	suppressedNew       = "first-observation"
+	// a metric did not move as store.Conditions require
+	suppressedConditions = "conditions-unmet"
+	// replaced by a market-wide summary
+	suppressedMarketWide = "market-wide"
+	// undelivered for longer than undelivered_ttl_hours
+	suppressedExpired = "undelivered-expired"
+	// the same floor was alerted just before a restart
+	suppressedRestart = "repeated-after-restart"
+)
+
+// fetchStats counts the fetches of a store
+type fetchStats struct {
+	requests int
+	errors   int
+	// total time spent fetching
+	latency time.Duration
+	// moving average of the latest fetches
+	recent time.Duration
+	// the operator was told fetches are slow
+	slowNotified bool
+}
+
+// weight of each fetch in fetchStats.recent
+const recentLatencyAlpha = 0.2
+
+// fetches a store needs before its average is a baseline to compare against
+const latencyBaselineFetches = 10
+
+// Override replaces the store thresholds of a single slug when set
+type Override struct {
+	Max       *float64 `json:"max,omitempty"`
+	Min       *float64 `json:"min,omitempty"`
+	Change    *float64 `json:"change,omitempty"`
+	Direction *string  `json:"direction,omitempty"`
+	Reference *float64 `json:"reference,omitempty"`
+	// percent away from reference to alert on
+	ReferenceChange *float64 `json:"reference_change,omitempty"`
+}
+
+func loadState(path string) (*State, error) {
+	state := &State{
+		path:           path,
+		Overrides:      map[string]*Override{},
+		EMA:            map[string]*EMA{},
+		Alerted:        map[string]time.Time{},
+		Snoozed:        map[string]time.Time{},
+		Muted:          map[string]bool{},
+		Watched:        map[string][]string{},
+		Unwatched:      map[string]bool{},
+		Undelivered:    map[string][]undeliveredAlert{},
+		Opens:          map[string]Open{},
+		Below:          map[string]bool{},
+		Anchors:        map[string]int64{},
+		AlertCounts:    map[string]int{},
+		Recent:         map[string]recentAlert{},
+		lastSuccess:    map[string]time.Time{},
+		staleNotified:  map[string]bool{},
+		frozenNotified: map[string]bool{},
+		failures:       map[string]int{},
+		errorNotified:  map[string]time.Time{},
+		held:           map[string]*heldAlert{},
+		suppressed:     map[string]int{},
+		fetches:        map[string]*fetchStats{},
+		deferred:       map[string][]Alert{},
+		lastSent:       map[string][sha256.Size]byte{},
+		suspects:       map[string]bool{},
+		slides:         map[string]*slide{},
+		responses:      map[string]lastResponse{},
+	}
+	content, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return state, err
+	}
+	err = json.Unmarshal(content, state)
+	if state.Overrides == nil {
+		state.Overrides = map[string]*Override{}
+	}
+	if state.EMA == nil {
+		state.EMA = map[string]*EMA{}
+	}
+	if state.Alerted == nil {
+		state.Alerted = map[string]time.Time{}
+	}
+	if state.Snoozed == nil {
+		state.Snoozed = map[string]time.Time{}
+	}
+	if state.Muted == nil {
+		state.Muted = map[string]bool{}
+	}
+	if state.Watched == nil {
+		state.Watched = map[string][]string{}
+	}
+	if state.Unwatched == nil {
+		state.Unwatched = map[string]bool{}
+	}
+	if state.Undelivered == nil {
+		state.Undelivered = map[string][]undeliveredAlert{}
+	}
+	if state.Opens == nil {
+		state.Opens = map[string]Open{}
+	}
+	if state.Below == nil {
+		state.Below = map[string]bool{}
+	}
+	if state.Anchors == nil {
+		state.Anchors = map[string]int64{}
+	}
+	if state.AlertCounts == nil {
+		state.AlertCounts = map[string]int{}
+	}
+	if state.Recent == nil {
+		state.Recent = map[string]recentAlert{}
+	}
+	return state, err
+}
+
+// save must be called with mu held. The state stays dirty if the write fails
+// so the next flush retries it
+func (s *State) save() error {
+	content, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	err = fileBlob(s.path).write(content)
+	if err == nil {
+		s.dirty = false
+	}
+	return err
+}
+
+// flush saves the state if it changed since the last save
+func (s *State) flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.dirty {
+		return nil
+	}
+	return s.save()
+}
+
+// setOverride applies fn to the slug's override and persists the result
+func (s *State) setOverride(slug string, fn func(*Override)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	override, ok := s.Overrides[slug]
+	if !ok {
+		override = &Override{}
+		s.Overrides[slug] = override
+	}
+	fn(override)
+	return s.save()
+}
+
+// apply returns the store config with the slug's overrides applied
+func (s *State) apply(store StoreConfig, slug string) StoreConfig {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	override, ok := s.Overrides[slug]
+	if !ok {
+		return store
+	}
+	if override.Max != nil {
+		store.Max = *override.Max
+	}
+	if override.Min != nil {
+		store.Min = *override.Min
+	}
+	if override.Change != nil {
+		store.MinChange = *override.Change
+		store.Tiers = nil
+	}
+	if override.Direction != nil {
+		store.Direction = *override.Direction
+	}
+	if override.Reference != nil {
+		// copy so other slugs of the store keep the configured map
+		references := map[string]float64{slug: *override.Reference}
+		for s, reference := range store.References {
+			if s != slug {
+				references[s] = reference
+			}
+		}
+		store.References = references
+	}
+	if override.ReferenceChange != nil {
+		store.ReferenceChange = *override.ReferenceChange
+	}
+	return store
+}
+
+// fetched records a successful fetch of slug
+func (s *State) fetched(slug string, date time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastSuccess[slug] = date
+	delete(s.staleNotified, slug)
+	delete(s.failures, slug)
+}
+
+// markStale returns true only the first time slug has had no successful fetch for window.
+// The window starts at the first failure for slugs that never succeeded
+func (s *State) markStale(slug string, date time.Time, window time.Duration) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	last, ok := s.lastSuccess[slug]
+	if !ok {
+		s.lastSuccess[slug] = date
+		return false
+	}
+	if s.staleNotified[slug] || date.Sub(last) < window {
+		return false
+	}
+	s.staleNotified[slug] = true
+	return true
+}
+
+// markFrozen returns true only when slug becomes frozen. It may become frozen again once it is not
+func (s *State) markFrozen(slug string, frozen bool) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !frozen {
+		delete(s.frozenNotified, slug)
+		return false
+	}
+	if s.frozenNotified[slug] {
+		return false
+	}
+	s.frozenNotified[slug] = true
+	return true
+}
+
+// failed counts a failed fetch of slug and returns true once it failed after times in a row
+// unless that was already reported less than interval ago
+func (s *State) failed(slug string, date time.Time, after int, interval time.Duration) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures[slug]++
+	if s.failures[slug] < after || date.Sub(s.errorNotified[slug]) < interval {
+		return false
+	}
+	s.errorNotified[slug] = date
+	return true
+}
+
+// coolingDown returns true if key alerted less than window before date unless bypass.
+// The alert is only recorded by startCooldowns once it is delivered
+func (s *State) coolingDown(key string, date time.Time, window time.Duration, bypass bool) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return !bypass && date.Sub(s.Alerted[key]) < window
+}
+
+// startCooldowns records the delivered alerts that passed a cooldown as the last alert of their key
+func (s *State) startCooldowns(alerts []Alert) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, alert := range alerts {
+		if !alert.cooldown {
+			continue
+		}
+		s.Alerted[metricKey(alert.Slug, alert.Metric)] = alert.Date
+		s.dirty = true
+	}
+}
+
+// hold buffers alert under key until the window from its first change ends
+func (s *State) hold(key string, store StoreConfig, alert Alert) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.held[key] = &heldAlert{store: store, alert: alert, floor: alert.Floor, date: alert.Date}
+}
+
+// absorb records value as the latest of the alert held under key.
+// Returns false if nothing is held
+func (s *State) absorb(key string, value float64, date time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	held, ok := s.held[key]
+	if !ok {
+		return false
+	}
+	held.floor = value
+	held.date = date
+	return true
+}
+
+// due removes and returns the alerts held for at least window
+func (s *State) due(now time.Time, window time.Duration) []*heldAlert {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var due []*heldAlert
+	for key, held := range s.held {
+		if now.Sub(held.alert.Date) < window {
+			continue
+		}
+		delete(s.held, key)
+		due = append(due, held)
+	}
+	return due
+}
+
+// suppress counts an alert not sent for reason
+func (s *State) suppress(reason string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.suppressed[reason]++
+}
+
+// suppressions returns a copy of the suppression counts
+func (s *State) suppressions() map[string]int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	counts := map[string]int{}
+	for reason, count := range s.suppressed {
+		counts[reason] = count
+	}
+	return counts
+}
+
+// countAlerts adds delivered alerts to the alert counts of their slugs
+func (s *State) countAlerts(alerts []Alert) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, alert := range alerts {
+		s.AlertCounts[alert.Slug]++
+	}
+	s.dirty = true
+}
+
+// alertCounts returns slug: count lines of the alert counts, most alerted first
+func (s *State) alertCounts() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var slugs []string
+	for slug := range s.AlertCounts {
+		slugs = append(slugs, slug)
+	}
+	sort.Slice(slugs, func(i, j int) bool {
+		if s.AlertCounts[slugs[i]] != s.AlertCounts[slugs[j]] {
+			return s.AlertCounts[slugs[i]] > s.AlertCounts[slugs[j]]
+		}
+		return slugs[i] < slugs[j]
+	})
+	lines := make([]string, len(slugs))
+	for i, slug := range slugs {
+		lines[i] = fmt.Sprintf("%s: %d", slug, s.AlertCounts[slug])
+	}
+	return lines
+}
+
+// recordFetch counts a fetch from store that took took
+func (s *State) recordFetch(store string, took time.Duration, failed bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stats, ok := s.fetches[store]
+	if !ok {
+		stats = &fetchStats{}
+		s.fetches[store] = stats
+	}
+	stats.requests++
+	stats.latency += took
+	if stats.requests == 1 {
+		stats.recent = took
+	} else {
+		stats.recent = time.Duration(recentLatencyAlpha*float64(took) + (1-recentLatencyAlpha)*float64(stats.recent))
+	}
+	if failed {
+		stats.errors++
+	}
+}
+
+// markSlow returns true only when the recent fetches of store start averaging more than limit
+// or more than factor times the average since start. It may become slow again once it is not
+func (s *State) markSlow(store string, limit time.Duration, factor float64) (fetchStats, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stats, ok := s.fetches[store]
+	if !ok {
+		return fetchStats{}, false
+	}
+	slow := limit > 0 && stats.recent > limit
+	baseline := stats.latency / time.Duration(stats.requests)
+	if factor > 0 && stats.requests >= latencyBaselineFetches && float64(stats.recent) > factor*float64(baseline) {
+		slow = true
+	}
+	if !slow {
+		stats.slowNotified = false
+		return *stats, false
+	}
+	if stats.slowNotified {
+		return *stats, false
+	}
+	stats.slowNotified = true
+	return *stats, true
+}
+
+// fetchCounts returns a copy of the fetch counts by store
+func (s *State) fetchCounts() map[string]fetchStats {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	counts := map[string]fetchStats{}
+	for store, stats := range s.fetches {
+		counts[store] = *stats
+	}
+	return counts
+}
+
+// startCycle counts a new cycle and returns its number starting from 1
+func (s *State) startCycle() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cycles++
+	return s.cycles
+}
+
+// silence snoozes slug until until or mutes it if until is zero and persists the result
+func (s *State) silence(slug string, until time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if until.IsZero() {
+		s.Muted[slug] = true
+	} else {
+		s.Snoozed[slug] = until
+	}
+	return s.save()
+}
+
+// unsilence clears the snooze and mute of slug
+func (s *State) unsilence(slug string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.Muted, slug)
+	delete(s.Snoozed, slug)
+	return s.save()
+}
+
+// silenced returns true if slug is muted or snoozed at date
+func (s *State) silenced(slug string, date time.Time) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.Muted[slug] || date.Before(s.Snoozed[slug])
+}
+
+// portfolioDue returns true and records now if the last portfolio summary was sent at least window ago
+func (s *State) portfolioDue(now time.Time, window time.Duration) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if now.Sub(s.PortfolioSent) < window {
+		return false
+	}
+	s.PortfolioSent = now
+	s.dirty = true
+	return true
+}
+
+// repeated returns true if sum is the hash of the last message sent to recipient
+func (s *State) repeated(recipient string, sum [sha256.Size]byte) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	last, ok := s.lastSent[recipient]
+	return ok && last == sum
+}
+
+// sent records sum as the hash of the last message sent to recipient
+func (s *State) sent(recipient string, sum [sha256.Size]byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastSent[recipient] = sum
+}
+
+// deferAlerts buffers alerts of recipient until releaseDeferred
+func (s *State) deferAlerts(recipient string, alerts []Alert) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.deferred[recipient] = append(s.deferred[recipient], alerts...)
+}
+
+// releaseDeferred removes and returns the buffered alerts by recipient
+func (s *State) releaseDeferred() map[string][]Alert {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	deferred := s.deferred
+	s.deferred = map[string][]Alert{}
+	return deferred
+}
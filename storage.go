@@ -0,0 +1,309 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// delay before the first retry of a failed read. It doubles with each retry
+const readRetryDelay = 100 * time.Millisecond
+
+// blob is where the floor history is kept.
+// read returns an error wrapping os.ErrNotExist while nothing has been written
+type blob interface {
+	read() ([]byte, error)
+	write(content []byte) error
+}
+
+// fileBlob is a local file
+type fileBlob string
+
+func (f fileBlob) read() ([]byte, error) {
+	return ioutil.ReadFile(string(f))
+}
+
+// write replaces the file through a rename so readers never see a partial write
+func (f fileBlob) write(content []byte) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(string(f)), filepath.Base(string(f))+".*")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(content)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), 0644)
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), string(f))
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
+
+// retryBlob retries failed reads of blob. A missing blob is not retried
+type retryBlob struct {
+	blob
+	retries int
+	delay   time.Duration
+}
+
+func (r retryBlob) read() ([]byte, error) {
+	delay := r.delay
+	content, err := r.blob.read()
+	for i := 0; i < r.retries && err != nil && !errors.Is(err, os.ErrNotExist); i++ {
+		fmt.Printf("read error: %v. retrying in %v\n", err, delay)
+		time.Sleep(delay)
+		delay *= 2
+		content, err = r.blob.read()
+	}
+	return content, err
+}
+
+// mirrorBlob reads primary and writes both.
+// A failed write of mirror is only printed so saves only fail with primary
+type mirrorBlob struct {
+	primary blob
+	mirror  blob
+}
+
+func (m mirrorBlob) read() ([]byte, error) {
+	return m.primary.read()
+}
+
+func (m mirrorBlob) write(content []byte) error {
+	if err := m.mirror.write(content); err != nil {
+		fmt.Printf("mirror write: %v\n", err)
+	}
+	return m.primary.write(content)
+}
+
+// splitBlob keeps the history of stores with their own history_json_path in that file
+// and the rest in rest. Reads merge every file so the history is seen as one
+type splitBlob struct {
+	rest blob
+	// history_format of every file
+	format string
+	routes []historyRoute
+}
+
+// historyRoute is the file of a store's slugs and slug_pattern matches
+type historyRoute struct {
+	path    string
+	slugs   []string
+	pattern *regexp.Regexp
+}
+
+// newSplitBlob returns rest, the history at output, unless a store has its own history_json_path
+func newSplitBlob(rest blob, format, output string, stores []StoreConfig) blob {
+	var routes []historyRoute
+	for _, store := range stores {
+		if store.Output == "" || store.Output == output {
+			continue
+		}
+		route := historyRoute{path: store.Output, slugs: store.Slugs}
+		if store.SlugPattern != "" {
+			// validated when the config was loaded
+			route.pattern = regexp.MustCompile(store.SlugPattern)
+		}
+		routes = append(routes, route)
+	}
+	if len(routes) == 0 {
+		return rest
+	}
+	return splitBlob{rest: rest, format: format, routes: routes}
+}
+
+// path returns the file of key, a slug or metric key, or "" for rest.
+// A slug of several stores goes to the first
+func (s splitBlob) path(key string) string {
+	slug := key
+	if i := strings.Index(key, "/"); i >= 0 {
+		slug = key[:i]
+	}
+	for _, route := range s.routes {
+		if containsString(route.slugs, key) || containsString(route.slugs, slug) {
+			return route.path
+		}
+		if route.pattern != nil && route.pattern.MatchString(slug) {
+			return route.path
+		}
+	}
+	return ""
+}
+
+// files returns the blob of each path with rest as ""
+func (s splitBlob) files() map[string]blob {
+	files := map[string]blob{"": s.rest}
+	for _, route := range s.routes {
+		files[route.path] = fileBlob(route.path)
+	}
+	return files
+}
+
+// read merges the history of every file by date
+func (s splitBlob) read() ([]byte, error) {
+	var history []Persisted
+	found := false
+	for _, file := range s.files() {
+		floors, err := readFloor(file, s.format)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		found = true
+		history = append(history, floors...)
+	}
+	if !found {
+		return nil, os.ErrNotExist
+	}
+	sort.SliceStable(history, func(i, j int) bool {
+		return history[i].Date.Before(history[j].Date)
+	})
+	return encodeHistory(s.format, history)
+}
+
+// write gives each file its part of the history.
+// Floors read from rest move to their store's file with the first write
+func (s splitBlob) write(content []byte) error {
+	history, err := decodeHistory(s.format, content)
+	if err != nil {
+		return err
+	}
+	parts := map[string][]Persisted{}
+	for _, persisted := range history {
+		path := s.path(persisted.Slug)
+		parts[path] = append(parts[path], persisted)
+	}
+	for path, file := range s.files() {
+		part := parts[path]
+		if part == nil {
+			part = []Persisted{}
+		}
+		encoded, err := encodeHistory(s.format, part)
+		if err != nil {
+			return err
+		}
+		if err := file.write(encoded); err != nil {
+			if path == "" {
+				return err
+			}
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	return nil
+}
+
+// s3Blob is an object of an s3 compatible store addressed path style as endpoint/bucket/key.
+// A put replaces the whole object so writes are atomic
+type s3Blob struct {
+	client *http.Client
+	clock  Clock
+	config S3Config
+}
+
+func (s s3Blob) read() ([]byte, error) {
+	res, err := s.do("GET", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	content, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, fmt.Errorf("s3: %w", err)
+	}
+	if res.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("s3 %s/%s: %w", s.config.Bucket, s.config.Key, os.ErrNotExist)
+	}
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("s3 get: %s %s", res.Status, content)
+	}
+	return content, nil
+}
+
+func (s s3Blob) write(content []byte) error {
+	res, err := s.do("PUT", content)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(res.Body)
+		return fmt.Errorf("s3 put: %s %s", res.Status, body)
+	}
+	return nil
+}
+
+// do sends a request for the object signed with aws signature version 4
+func (s s3Blob) do(method string, body []byte) (*http.Response, error) {
+	endpoint, err := url.Parse(strings.TrimSuffix(s.config.Endpoint, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("s3 endpoint: %w", err)
+	}
+	endpoint.Path += "/" + s.config.Bucket + "/" + strings.TrimPrefix(s.config.Key, "/")
+	req, err := http.NewRequest(method, endpoint.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	now := s.clock.Now().UTC()
+	date := now.Format("20060102")
+	stamp := now.Format("20060102T150405Z")
+	payload := sha256Hex(body)
+	req.Header.Set("x-amz-content-sha256", payload)
+	req.Header.Set("x-amz-date", stamp)
+
+	signed := "host;x-amz-content-sha256;x-amz-date"
+	canonical := strings.Join([]string{
+		method,
+		endpoint.EscapedPath(),
+		"",
+		"host:" + endpoint.Host,
+		"x-amz-content-sha256:" + payload,
+		"x-amz-date:" + stamp,
+		"",
+		signed,
+		payload,
+	}, "\n")
+	scope := date + "/" + s.config.Region + "/s3/aws4_request"
+	toSign := "AWS4-HMAC-SHA256\n" + stamp + "\n" + scope + "\n" + sha256Hex([]byte(canonical))
+	key := []byte("AWS4" + s.config.SecretKey)
+	for _, part := range []string{date, s.config.Region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, toSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s", s.config.AccessKey, scope, signed, signature))
+	res, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("s3: %w", err)
+	}
+	return res, nil
+}
+
+func sha256Hex(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, message string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(message))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// checkSweep alerts when the latest floor of slug completes a run of at least sweep_drops
+// consecutive drops totaling sweep_change percent. A run alerts once, when it first qualifies
+func (c *cycle) checkSweep(store StoreConfig, slug string) {
+	now := c.deps.Clock.Now()
+	recent := c.floors.Recent(slug, now.Add(-time.Duration(store.SweepMinutes*float64(time.Minute))))
+	// start of the run of drops ending at the latest floor
+	start := len(recent) - 1
+	for start > 0 && recent[start-1].Floor > recent[start].Floor {
+		start--
+	}
+	drops := len(recent) - 1 - start
+	if drops < store.SweepDrops {
+		return
+	}
+	first, last := recent[start].Floor, recent[len(recent)-1].Floor
+	change := (last - first) / first * 100
+	if -change < store.SweepChange {
+		return
+	}
+	if drops > store.SweepDrops {
+		previous := (recent[len(recent)-2].Floor - first) / first * 100
+		if -previous >= store.SweepChange {
+			// already alerted for this run
+			return
+		}
+	}
+	alert := newAlert(store, c.recipient(store), slug, "sweep", last, first, now)
+	alert.Change = change
+	alert.Message = fmt.Sprintf("possible sweep: %s %s → %s over %d drops (%s)", slugLink(store, slug), formatFloor(store, first), formatFloor(store, last), drops, formatPercent(store, change))
+	c.queue(alert)
+}
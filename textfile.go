@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// writeMetricsFile replaces config.MetricsFile with the latest floors and counters
+// in the prometheus text format read by the node_exporter textfile collector
+func (c *cycle) writeMetricsFile() error {
+	var b strings.Builder
+	b.WriteString("# TYPE floorbot_floor gauge\n")
+	for _, store := range c.config.Stores {
+		if !store.Enabled {
+			continue
+		}
+		for _, slug := range store.Slugs {
+			if floor := c.floors.Get(slug); floor > 0 {
+				fmt.Fprintf(&b, "floorbot_floor{store=\"%s\",slug=\"%s\"} %v\n", labelEscaper.Replace(store.label()), labelEscaper.Replace(slug), floor)
+			}
+		}
+	}
+	fetches := c.state.fetchCounts()
+	var stores []string
+	for store := range fetches {
+		stores = append(stores, store)
+	}
+	sort.Strings(stores)
+	b.WriteString("# TYPE floorbot_fetches_total counter\n")
+	for _, store := range stores {
+		fmt.Fprintf(&b, "floorbot_fetches_total{store=\"%s\"} %d\n", labelEscaper.Replace(store), fetches[store].requests)
+	}
+	b.WriteString("# TYPE floorbot_fetch_errors_total counter\n")
+	for _, store := range stores {
+		fmt.Fprintf(&b, "floorbot_fetch_errors_total{store=\"%s\"} %d\n", labelEscaper.Replace(store), fetches[store].errors)
+	}
+	b.WriteString("# TYPE floorbot_fetch_seconds_total counter\n")
+	for _, store := range stores {
+		fmt.Fprintf(&b, "floorbot_fetch_seconds_total{store=\"%s\"} %v\n", labelEscaper.Replace(store), fetches[store].latency.Seconds())
+	}
+	b.WriteString("# TYPE floorbot_fetch_recent_seconds gauge\n")
+	for _, store := range stores {
+		fmt.Fprintf(&b, "floorbot_fetch_recent_seconds{store=\"%s\"} %v\n", labelEscaper.Replace(store), fetches[store].recent.Seconds())
+	}
+	suppressed := c.state.suppressions()
+	var reasons []string
+	for reason := range suppressed {
+		reasons = append(reasons, reason)
+	}
+	sort.Strings(reasons)
+	b.WriteString("# TYPE floorbot_suppressed_alerts_total counter\n")
+	for _, reason := range reasons {
+		fmt.Fprintf(&b, "floorbot_suppressed_alerts_total{reason=\"%s\"} %d\n", reason, suppressed[reason])
+	}
+	return fileBlob(c.config.MetricsFile).write([]byte(b.String()))
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// errAnchorGone is returned by telegramNotifier when the anchor an alert replies to was deleted
+var errAnchorGone = errors.New("anchor message not found")
+
+func anchorKey(recipient, slug string) string {
+	return recipient + " " + slug
+}
+
+// anchor returns the message the alerts of slug reply to in recipient or 0 if none was sent
+func (s *State) anchor(recipient, slug string) int64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.Anchors[anchorKey(recipient, slug)]
+}
+
+// setAnchor records the anchor of slug in recipient. 0 forgets it
+func (s *State) setAnchor(recipient, slug string, id int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if id == 0 {
+		delete(s.Anchors, anchorKey(recipient, slug))
+	} else {
+		s.Anchors[anchorKey(recipient, slug)] = id
+	}
+	s.dirty = true
+}
+
+// threadAlerts makes alerts of a single slug reply to its anchor in recipient,
+// sending the anchor first if the slug has none yet. Alerts of several slugs are left alone
+func threadAlerts(deps Deps, config Config, state *State, recipient string, alerts []Alert) []Alert {
+	slug := alerts[0].Slug
+	for _, alert := range alerts[1:] {
+		if alert.Slug != slug {
+			return alerts
+		}
+	}
+	id := state.anchor(recipient, slug)
+	if id == 0 {
+		sent, err := sendMarkup(deps.Telegram, config.Telegram, recipient, fmt.Sprintf("%s alerts", slug), nil)
+		if err != nil {
+			fmt.Println("anchor:", err)
+			return alerts
+		}
+		id = sent.MessageID
+		state.setAnchor(recipient, slug, id)
+	}
+	threaded := make([]Alert, len(alerts))
+	for i, alert := range alerts {
+		alert.replyTo = id
+		threaded[i] = alert
+	}
+	return threaded
+}
+
+// anchorGone reports whether telegram refused a reply because its message was deleted
+func anchorGone(err error) bool {
+	text := err.Error()
+	return strings.Contains(text, "replied") && strings.Contains(text, "not found")
+}
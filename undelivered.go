@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// undeliveredAlert is an alert no notifier could deliver, saved with the state until it is
+type undeliveredAlert struct {
+	Alert
+	Text   string `json:"message"`
+	Header string `json:"header,omitempty"`
+	Footer string `json:"footer,omitempty"`
+	// the alert's cooldown starts when a retry delivers it
+	Cooldown bool `json:"cooldown,omitempty"`
+}
+
+// keepUndelivered buffers alerts of recipient for retryUndelivered, keeping the latest limit.
+// Saved right away since the cycle's state was already flushed
+func (s *State) keepUndelivered(recipient string, alerts []Alert, limit int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	buffered := s.Undelivered[recipient]
+	for _, alert := range alerts {
+		buffered = append(buffered, undeliveredAlert{alert, alert.Message, alert.header, alert.footer, alert.cooldown})
+	}
+	if dropped := len(buffered) - limit; dropped > 0 {
+		fmt.Printf("undelivered buffer for %s is full. dropping %d oldest alerts\n", recipient, dropped)
+		buffered = buffered[dropped:]
+	}
+	s.Undelivered[recipient] = buffered
+	return s.save()
+}
+
+// takeUndelivered removes and returns the buffered alerts by recipient
+// without those older than ttl. 0 ttl keeps every alert
+func (s *State) takeUndelivered(now time.Time, ttl time.Duration) map[string][]Alert {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.Undelivered) == 0 {
+		return nil
+	}
+	pending := map[string][]Alert{}
+	for recipient, buffered := range s.Undelivered {
+		for _, kept := range buffered {
+			if ttl > 0 && now.Sub(kept.Date) > ttl {
+				s.suppressed[suppressedExpired]++
+				continue
+			}
+			alert := kept.Alert
+			alert.Message, alert.header, alert.footer, alert.cooldown = kept.Text, kept.Header, kept.Footer, kept.Cooldown
+			pending[recipient] = append(pending[recipient], alert)
+		}
+	}
+	s.Undelivered = map[string][]undeliveredAlert{}
+	s.dirty = true
+	return pending
+}
+
+// retryUndelivered sends the alerts buffered while every notifier failed before the cycle's own.
+// They are buffered again if sending still fails
+func (c *cycle) retryUndelivered() {
+	ttl := time.Duration(c.config.UndeliveredHours * float64(time.Hour))
+	for recipient, alerts := range c.state.takeUndelivered(c.deps.Clock.Now(), ttl) {
+		fmt.Printf("retrying %d undelivered alerts to %s\n", len(alerts), recipient)
+		sendAlerts(c.deps, c.config, c.state, recipient, alerts)
+	}
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	coingeckoURL = "https://api.coingecko.com/api/v3/simple/price"
+	coinbaseURL  = "https://api.coinbase.com/v2/prices/%s-USD/spot"
+)
+
+// rateSource fetches the usd price of each currency
+type rateSource interface {
+	fetch(client *http.Client, ids []string) (map[string]float64, error)
+}
+
+// rateSources are selectable through usd_rates.source
+var rateSources = map[string]rateSource{
+	"coingecko": coingecko{},
+	"coinbase":  coinbase{},
+}
+
+// coingecko prices currencies by coingecko id e.g. ethereum
+type coingecko struct{}
+
+func (coingecko) fetch(client *http.Client, ids []string) (map[string]float64, error) {
+	rates := map[string]float64{}
+	query := url.Values{}
+	query.Set("ids", strings.Join(ids, ","))
+	query.Set("vs_currencies", "usd")
+	res, err := client.Get(coingeckoURL + "?" + query.Encode())
+	if err != nil {
+		return rates, fmt.Errorf("coingecko: %w", err)
+	}
+	defer res.Body.Close()
+	var prices map[string]map[string]float64
+	err = json.NewDecoder(res.Body).Decode(&prices)
+	if err != nil {
+		return rates, fmt.Errorf("coingecko: %w", err)
+	}
+	for id, price := range prices {
+		rates[id] = price["usd"]
+	}
+	return rates, nil
+}
+
+// coinbaseSymbols maps coingecko ids to coinbase symbols so currency works with either source
+var coinbaseSymbols = map[string]string{
+	"ethereum":      "ETH",
+	"weth":          "ETH",
+	"solana":        "SOL",
+	"matic-network": "MATIC",
+	"avalanche-2":   "AVAX",
+	"binancecoin":   "BNB",
+	"tezos":         "XTZ",
+	"cardano":       "ADA",
+	"flow":          "FLOW",
+}
+
+// coinbase prices currencies by symbol e.g. ETH, one request per currency
+type coinbase struct{}
+
+func (coinbase) fetch(client *http.Client, ids []string) (map[string]float64, error) {
+	rates := map[string]float64{}
+	for _, id := range ids {
+		symbol, ok := coinbaseSymbols[id]
+		if !ok {
+			symbol = strings.ToUpper(id)
+		}
+		res, err := client.Get(fmt.Sprintf(coinbaseURL, url.PathEscape(symbol)))
+		if err != nil {
+			return rates, fmt.Errorf("coinbase: %w", err)
+		}
+		var price struct {
+			Data struct {
+				Amount string `json:"amount"`
+			} `json:"data"`
+		}
+		err = json.NewDecoder(res.Body).Decode(&price)
+		res.Body.Close()
+		if err != nil {
+			return rates, fmt.Errorf("coinbase %s: %w", symbol, err)
+		}
+		rate, err := strconv.ParseFloat(price.Data.Amount, 64)
+		if err != nil {
+			return rates, fmt.Errorf("coinbase %s: no price", symbol)
+		}
+		rates[id] = rate
+	}
+	return rates, nil
+}
+
+// rateCache reuses prices from source until they are older than ttl
+type rateCache struct {
+	source  rateSource
+	ttl     time.Duration
+	mu      sync.Mutex
+	rates   map[string]float64
+	fetched time.Time
+}
+
+func newRateCache(source rateSource, ttl time.Duration) *rateCache {
+	return &rateCache{source: source, ttl: ttl, rates: map[string]float64{}}
+}
+
+// get returns the usd price of each id, fetching only when the cache expired or misses an id.
+// Cached prices are returned alongside the error if a refresh fails
+func (r *rateCache) get(client *http.Client, now time.Time, ids []string) (map[string]float64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	fresh := now.Sub(r.fetched) < r.ttl
+	for _, id := range ids {
+		if _, ok := r.rates[id]; !ok {
+			fresh = false
+		}
+	}
+	if fresh || len(ids) == 0 {
+		return r.rates, nil
+	}
+	rates, err := r.source.fetch(client, ids)
+	if err != nil {
+		return r.rates, err
+	}
+	r.rates = rates
+	r.fetched = now
+	return rates, nil
+}
+
+// currencies returns the currency ids used by stores
+func currencies(stores []StoreConfig) []string {
+	seen := map[string]bool{}
+	var ids []string
+	add := func(id string) {
+		if id != "" && !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	for _, store := range stores {
+		add(store.Currency)
+		if store.DisplayCurrency == "" {
+			continue
+		}
+		add(store.DisplayCurrency)
+		var symbols []string
+		for symbol := range store.SymbolIDs {
+			symbols = append(symbols, symbol)
+		}
+		sort.Strings(symbols)
+		for _, symbol := range symbols {
+			add(store.SymbolIDs[symbol])
+		}
+	}
+	return ids
+}
+
+func formatUSD(store StoreConfig, usd float64) string {
+	return "$" + formatNumber(usd, 2, store.SignificantFigures, store.ThousandsSeparator)
+}
+
+// checkUSD alerts when the usd value of the floor moves by usd_change percent even if the floor did not.
+// The usd baseline follows the floor whenever the floor itself changes
+func (c *cycle) checkUSD(store StoreConfig, slug string, floor, rate float64, floorChanged bool) {
+	key := metricKey(slug, "usd")
+	usd := floor * rate
+	old := c.floors.Get(key)
+	if old == 0 || floorChanged {
+		c.floors.Set(key, usd, c.deps.Clock.Now())
+		return
+	}
+	change := (usd - old) / old * 100
+	if store.USDChange <= 0 || math.Abs(change) < store.USDChange {
+		return
+	}
+	c.floors.Set(key, usd, c.deps.Clock.Now())
+	alert := newAlert(store, c.recipient(store), slug, "usd", usd, old, c.deps.Clock.Now())
+	alert.Change = change
+	alert.USD = usd
+	alert.Message = fmt.Sprintf("%s: %s %s (%s USD)", slugLink(store, slug), formatFloor(store, floor), formatUSD(store, usd), formatPercent(store, change))
+	c.queue(alert)
+}
+
+// usdThresholds converts max_usd and min_usd to the store's currency at the cycle's rate.
+// While there is no rate they are skipped for the cycle and max and min apply
+func (c *cycle) usdThresholds(store StoreConfig) StoreConfig {
+	if store.MaxUSD == 0 && store.MinUSD == 0 {
+		return store
+	}
+	rate := c.rates[store.Currency]
+	if rate <= 0 {
+		fmt.Printf("no usd rate for %q. using max and min\n", store.Currency)
+		return store
+	}
+	if store.MaxUSD > 0 {
+		store.Max = store.MaxUSD / rate
+	}
+	if store.MinUSD > 0 {
+		store.Min = store.MinUSD / rate
+	}
+	return store
+}
@@ -0,0 +1,81 @@
+package main
+
+import "fmt"
+
+// VariantConfig is the same collection priced in another currency,
+// e.g. a stablecoin floor next to the eth floor. Unset fields are the store's
+type VariantConfig struct {
+	// labels alerts and keys history as <slug>/<name>
+	Name        string            `json:"name"`
+	StatsURL    string            `json:"stats_url"`
+	QueryParams map[string]string `json:"query_params"`
+	Tree        []string          `json:"json_map"`
+	JQ          string            `json:"jq"`
+	Multiplier  float64           `json:"multiplier"`
+	Max         float64           `json:"max"`
+	Min         float64           `json:"min"`
+	MinChange   float64           `json:"min_change"`
+	// coingecko id for usd values. Empty for none
+	Currency string `json:"currency"`
+}
+
+// apply returns the store fetching and alerting on the variant
+func (v VariantConfig) apply(store StoreConfig) StoreConfig {
+	if v.StatsURL != "" {
+		store.StatsURL = v.StatsURL
+		store.GraphQLQuery = ""
+	}
+	if v.QueryParams != nil {
+		store.QueryParams = v.QueryParams
+	}
+	if len(v.Tree) > 0 || v.JQ != "" {
+		store.Tree = v.Tree
+		store.JQ = v.JQ
+	}
+	if v.Multiplier != 0 {
+		store.Multiplier = v.Multiplier
+	}
+	if v.Max != 0 {
+		store.Max = v.Max
+	}
+	if v.Min != 0 {
+		store.Min = v.Min
+	}
+	if v.MinChange != 0 {
+		store.MinChange = v.MinChange
+		store.Tiers = nil
+	}
+	store.Currency = v.Currency
+	// in the store's currency
+	store.MinAbsChange = 0
+	store.Symbol = ""
+	store.SymbolTree = nil
+	store.SymbolDecimals = nil
+	store.DisplayCurrency = ""
+	// these read the main response
+	store.VolumeTree = nil
+	store.SalesTree = nil
+	store.ListingsTree = nil
+	store.Metrics = nil
+	store.Conditions = nil
+	return store
+}
+
+// watchVariants fetches and checks the floor of slug in each of the store's currency variants
+func (c *cycle) watchVariants(store StoreConfig, slug string) {
+	for _, variant := range store.Variants {
+		variantStore := variant.apply(store)
+		stats, err := fetchFloor(c.deps.Stats, statsURL(variantStore, slug), slug, variantStore)
+		if err != nil {
+			fmt.Println(variant.Name, err)
+			c.mu.Lock()
+			c.errors++
+			c.mu.Unlock()
+			continue
+		}
+		c.mu.Lock()
+		c.fetched[metricKey(slug, variant.Name)] = stats.Floor
+		c.mu.Unlock()
+		c.checkChange(variantStore, slug, variant.Name, stats.Floor, stats)
+	}
+}
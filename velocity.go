@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+)
+
+// checkVelocity alerts when the floor of slug moves faster than velocity_per_hour percent per hour
+// measured from its first floor of the last velocity_minutes. It alerts once when the pace first gets there
+func (c *cycle) checkVelocity(store StoreConfig, slug string) {
+	now := c.deps.Clock.Now()
+	recent := c.floors.Recent(slug, now.Add(-time.Duration(store.VelocityMinutes*float64(time.Minute))))
+	if len(recent) < 2 {
+		return
+	}
+	first := recent[0]
+	pace := func(persisted Persisted) float64 {
+		hours := persisted.Date.Sub(first.Date).Hours()
+		if hours <= 0 || first.Floor <= 0 {
+			return 0
+		}
+		return (persisted.Floor - first.Floor) / first.Floor * 100 / hours
+	}
+	last := recent[len(recent)-1]
+	velocity := pace(last)
+	if math.Abs(velocity) < store.VelocityPerHour {
+		return
+	}
+	if len(recent) > 2 && math.Abs(pace(recent[len(recent)-2])) >= store.VelocityPerHour {
+		// already alerted for this move
+		return
+	}
+	alert := newAlert(store, c.recipient(store), slug, "velocity", last.Floor, first.Floor, now)
+	alert.Change = (last.Floor - first.Floor) / first.Floor * 100
+	minutes := strconv.FormatFloat(math.Round(last.Date.Sub(first.Date).Minutes()), 'f', -1, 64)
+	alert.Message = fmt.Sprintf("fast move: %s %s → %s in %s minutes (%s, %s/h)", slugLink(store, slug), formatFloor(store, first.Floor), formatFloor(store, last.Floor), minutes, formatPercent(store, alert.Change), formatPercent(store, velocity))
+	c.queue(alert)
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+)
+
+// watched returns config with the slugs added and removed through /watch and /unwatch
+func (s *State) watched(config Config) Config {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if len(s.Watched) == 0 && len(s.Unwatched) == 0 {
+		return config
+	}
+	stores := make([]StoreConfig, len(config.Stores))
+	for i, store := range config.Stores {
+		var slugs []string
+		for _, slug := range append(store.Slugs, s.Watched[store.label()]...) {
+			if !s.Unwatched[slug] && !containsString(slugs, slug) {
+				slugs = append(slugs, slug)
+			}
+		}
+		store.Slugs = slugs
+		stores[i] = store
+	}
+	config.Stores = stores
+	return config
+}
+
+// watch adds slug to the stores labeled label and persists it
+func (s *State) watch(label, slug string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.Unwatched, slug)
+	if !containsString(s.Watched[label], slug) {
+		s.Watched[label] = append(s.Watched[label], slug)
+	}
+	return s.save()
+}
+
+// unwatch removes slug from every store and persists it
+func (s *State) unwatch(slug string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for label, slugs := range s.Watched {
+		var kept []string
+		for _, watched := range slugs {
+			if watched != slug {
+				kept = append(kept, watched)
+			}
+		}
+		if len(kept) == 0 {
+			delete(s.Watched, label)
+			continue
+		}
+		s.Watched[label] = kept
+	}
+	s.Unwatched[slug] = true
+	return s.save()
+}
+
+// watchCommand fetches slug from the store before watching it so typos are not saved
+func watchCommand(ctx commandContext, args []string) (string, error) {
+	if len(args) != 2 {
+		return "", fmt.Errorf("expected 2 arguments")
+	}
+	label, slug := args[0], args[1]
+	var labels []string
+	for _, store := range ctx.config.Stores {
+		if store.label() != label {
+			labels = append(labels, store.label())
+			continue
+		}
+		if containsString(store.Slugs, slug) {
+			return fmt.Sprintf("%s is already watched on %s", slug, label), nil
+		}
+		stats, err := fetchFloor(ctx.deps.Stats, statsURL(store, slug), slug, store)
+		if err != nil {
+			return fmt.Sprintf("not watching %s: %v", slug, err), nil
+		}
+		if ctx.floors.Get(slug) == 0 {
+			// baseline for the next cycle instead of a new alert
+			ctx.floors.Set(slug, stats.Floor, ctx.deps.Clock.Now())
+		}
+		err = ctx.state.watch(label, slug)
+		if err != nil {
+			return "", fmt.Errorf("could not save: %w", err)
+		}
+		return fmt.Sprintf("watching %s on %s from the next cycle. floor %s", slug, label, formatFloor(store, stats.Floor)), nil
+	}
+	return "", fmt.Errorf("unknown store %s. stores: %v", label, labels)
+}
+
+func unwatchCommand(ctx commandContext, args []string) (string, error) {
+	if len(args) != 1 {
+		return "", fmt.Errorf("expected 1 argument")
+	}
+	slug := args[0]
+	if !isWatched(ctx.config, slug) {
+		return "", fmt.Errorf("%s is not watched", slug)
+	}
+	err := ctx.state.unwatch(slug)
+	if err != nil {
+		return "", fmt.Errorf("could not save: %w", err)
+	}
+	return fmt.Sprintf("stopped watching %s from the next cycle", slug), nil
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// WatchlistConfig is a separate list of stores run alongside the main one
+// with its own history, state and recipient. Other settings are shared
+type WatchlistConfig struct {
+	Name   string        `json:"name"`
+	Stores []StoreConfig `json:"stores"`
+	Output string        `json:"history_json_path"`
+	// defaults to <name>_state.json
+	StatePath string `json:"state_json_path"`
+	// defaults to telegram.recipient_id
+	RecipientID string `json:"recipient_id"`
+}
+
+// watchlist returns the config the list runs with
+func (config Config) watchlist(list WatchlistConfig) Config {
+	config.Stores = list.Stores
+	config.Output = list.Output
+	config.StatePath = list.StatePath
+	if list.RecipientID != "" {
+		config.Telegram.RecipientID = list.RecipientID
+	}
+	// only one list can listen on these
+	config.Telegram.ListenCommands = false
+	config.Socket = ""
+	config.S3.Bucket = ""
+	config.Watchlists = nil
+	config.Remote = RemoteConfig{}
+	return config
+}
+
+// watcher runs the cycles of one list
+type watcher struct {
+	deps   Deps
+	config Config
+	state  *State
+	floors *FloorStore
+	guard  cycleGuard
+	// effective config of the last cycle
+	snapshot configSnapshot
+	// stores of remote_config and when they were last read
+	remoteStores []StoreConfig
+	remoteRead   time.Time
+
+	mu sync.Mutex
+	// config of the last cycle with remote stores applied, before /watch slugs
+	applied Config
+}
+
+// newWatcher loads the state and history of config and seeds floors missing from it
+func newWatcher(deps Deps, config Config) *watcher {
+	var state *State
+	err := retryStartup(func() error {
+		var err error
+		state, err = loadState(config.StatePath)
+		return err
+	})
+	if err != nil {
+		log.Fatal("Cannot load state file: ", err)
+	}
+	state.started = deps.Clock.Now()
+	floors, err := openFloorStore(deps.History, config.HistoryFormat, time.Duration(config.PersistInterval*float64(time.Second)), config.CompactHistory)
+	if err != nil {
+		// saving over a history that could not be read would lose it
+		log.Fatal("Cannot read floor history: ", err)
+	}
+	seeded := seedFloors(deps, config, floors)
+	fmt.Printf("seeded %d collections\n", len(seeded))
+	if config.SeedSummary && len(seeded) > 0 {
+		sendSeedSummary(deps, config, seeded)
+	}
+	w := &watcher{deps: deps, config: config, state: state, floors: floors, guard: make(cycleGuard, 1), applied: config}
+	if config.Telegram.ListenCommands {
+		go listenCommands(commandContext{deps: deps, config: config, state: state, floors: floors, watcher: w})
+	}
+	return w
+}
+
+func (w *watcher) cycle() string {
+	config := w.applyRemote(w.config)
+	w.mu.Lock()
+	w.applied = config
+	w.mu.Unlock()
+	config = w.state.watched(config)
+	w.logConfigChanges(config)
+	return watchFloor(w.deps, config, w.state, w.floors)
+}
+
+// current returns the config the last cycle ran with so commands see the stores of remote_config
+func (w *watcher) current() Config {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.applied
+}
+
+// loop starts a cycle every tick unless the previous one is still running.
+// With align_to_seconds ticks fall on multiples of it on the wall clock instead
+func (w *watcher) loop() {
+	interval := 800 * time.Millisecond
+	if align := time.Duration(w.config.AlignSeconds * float64(time.Second)); align > 0 {
+		interval = align
+		now := time.Now()
+		time.Sleep(now.Truncate(align).Add(align).Sub(now))
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for ; true; <-ticker.C {
+		if !w.guard.tryLock() {
+			fmt.Println("previous cycle still running. skipping")
+			continue
+		}
+		go func() {
+			defer w.guard.unlock()
+			w.cycle()
+		}()
+	}
+}